@@ -0,0 +1,99 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kwadkore/ws-scraper/fetch"
+	"github.com/spf13/cobra"
+)
+
+// loadCardDir reads every card file under dir. Files that aren't a single
+// card, like booster or set files, are skipped.
+func loadCardDir(dir string) ([]fetch.Card, error) {
+	var cards []fetch.Card
+	err := walkExistingCards(dir, func(card fetch.Card, path string) {
+		cards = append(cards, card)
+	})
+	return cards, err
+}
+
+func printDiff(diff fetch.CardDiff) {
+	for _, card := range diff.Added {
+		fmt.Printf("+ %v (%v) %v\n", card.CardNumber, card.Language, card.Name)
+	}
+	for _, card := range diff.Removed {
+		fmt.Printf("- %v (%v) %v\n", card.CardNumber, card.Language, card.Name)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %v (%v)\n", change.CardNumber, change.Language)
+		for _, field := range change.Fields {
+			fmt.Printf("    %v: %v -> %v\n", field.Field, formatDiffValue(field.Old), formatDiffValue(field.New))
+		}
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
+
+func formatDiffValue(v any) string {
+	if s, ok := v.([]string); ok {
+		return "[" + strings.Join(s, " | ") + "]"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v))
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare two card directories",
+	Long: `Compare two card directories written by fetch.
+
+Cards are matched by card number and language, and added, removed and changed
+cards are printed with the fields that changed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldDir, _ := cmd.Flags().GetString("old")
+		newDir, _ := cmd.Flags().GetString("new")
+		oldCards, err := loadCardDir(oldDir)
+		if err != nil {
+			return fmt.Errorf("failed to load %v: %v", oldDir, err)
+		}
+		newCards, err := loadCardDir(newDir)
+		if err != nil {
+			return fmt.Errorf("failed to load %v: %v", newDir, err)
+		}
+
+		diff := fetch.DiffCards(oldCards, newCards)
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			return enc.Encode(diff)
+		}
+		printDiff(diff)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().String("old", "", "Card directory before the update")
+	diffCmd.Flags().String("new", "", "Card directory after the update")
+	diffCmd.Flags().Bool("json", false, "Print the differences as JSON")
+	diffCmd.MarkFlagRequired("old")
+	diffCmd.MarkFlagRequired("new")
+}
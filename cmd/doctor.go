@@ -0,0 +1,65 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kwadkore/ws-scraper/fetch"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the sites can still be scraped",
+	Long: `Fetch the card list and a search results page of each site and check that
+the selectors the scraper relies on are still there.
+
+Every check is printed and the command fails if any of them does, which usually
+means the site layout changed and extraction will break.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		langs, _ := cmd.Flags().GetStringSlice("lang")
+		useGET, _ := cmd.Flags().GetBool("get")
+		failed := 0
+		for _, l := range langs {
+			lang, siteLang := parseSiteLanguage(l)
+			checks, err := fetch.CheckSite(fetch.Config{Language: siteLang, UseGET: useGET})
+			if err != nil {
+				return err
+			}
+			for _, c := range checks {
+				if c.Err != nil {
+					failed++
+					fmt.Printf("FAIL %v %v %v: %v\n", lang, c.URL, c.Selector, c.Err)
+				} else {
+					fmt.Printf("ok   %v %v %v\n", lang, c.URL, c.Selector)
+				}
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringSlice("lang", []string{"en", "ja"}, "Site languages to check")
+	doctorCmd.Flags().Bool("get", false, "Also check that a GET search of an expansion finds as much as the POST form, for fetch --get")
+}
@@ -16,18 +16,24 @@ package cmd
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/kwadkore/ws-scraper/fetch"
 	"github.com/spf13/cobra"
@@ -37,77 +43,348 @@ import (
 
 const maxWorker int = 5
 
-func writeCards(wg *sync.WaitGroup, lang language.Tag, cardCh <-chan fetch.Card) {
+// partitionSchemes are the supported ways of laying out card files under cardDir.
+var partitionSchemes = []string{"setrelease", "rarity", "type", "color"}
+
+// cardPartition returns the directories a card goes into for the given scheme.
+func cardPartition(card fetch.Card, scheme string) []string {
+	var parts []string
+	switch scheme {
+	case "rarity":
+		parts = []string{card.Rarity}
+	case "type":
+		parts = []string{card.Type}
+	case "color":
+		parts = []string{card.Color}
+	default:
+		parts = []string{card.SetID, card.Release}
+	}
+	for i, p := range parts {
+		if p == "" {
+			parts[i] = "unknown"
+		}
+	}
+	return parts
+}
+
+// compactJSON strips the indentation from data so card files can be compared
+// whatever their layout.
+func compactJSON(data []byte) []byte {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, data); err != nil {
+		return data
+	}
+	return buffer.Bytes()
+}
+
+// cardIndex maps card numbers to their file, relative to the language's card
+// directory. It's shared by the writeCards goroutines.
+type cardIndex struct {
+	mu    sync.Mutex
+	paths map[string]string
+}
+
+func newCardIndex() *cardIndex {
+	return &cardIndex{paths: make(map[string]string)}
+}
+
+// add records a card's file. A nil index does nothing.
+func (idx *cardIndex) add(cardNumber, path string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.paths[cardNumber] = filepath.ToSlash(path)
+}
+
+// write saves the index as index.json in dir.
+func (idx *cardIndex) write(dir string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	res, err := json.MarshalIndent(idx.paths, "", "\t")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(dir, 0o744)
+	return os.WriteFile(filepath.Join(dir, "index.json"), res, 0o644)
+}
+
+// defaultFilenameTemplate is the --filename-template default.
+const defaultFilenameTemplate = "{{.SetID}}-{{.Release}}-{{.ID}}.json"
+
+// cardFileReplacer makes card values safe to use in file names, e.g. the / of
+// card numbers.
+var cardFileReplacer = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+
+// parseFilenameTemplate parses a --filename-template.
+func parseFilenameTemplate(text string) (*template.Template, error) {
+	return template.New("filename").Option("missingkey=error").Parse(text)
+}
+
+// cardFileName runs tmpl on card to get the path of its file, relative to the
+// partition directory. The card's values are made safe for file names first,
+// so only the template itself can add directories.
+func cardFileName(tmpl *template.Template, card fetch.Card) (string, error) {
+	v := reflect.ValueOf(&card).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.String {
+			f.SetString(cardFileReplacer.Replace(f.String()))
+		}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, card); err != nil {
+		return "", err
+	}
+	name := filepath.Clean(b.String())
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file name %q", b.String())
+	}
+	return name, nil
+}
+
+func writeCards(wg *sync.WaitGroup, cardDir string, lang language.Tag, cfg fetch.Config, idx *cardIndex, tmpl *template.Template, cardCh <-chan fetch.Card) {
 	for card := range cardCh {
-		res, errMarshal := json.Marshal(card)
-		if errMarshal != nil {
-			slog.Error(fmt.Sprintf("error marshalling: %v", errMarshal))
+		cardName, err := cardFileName(tmpl, card)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error naming card %v: %v", card.CardNumber, err))
 			continue
 		}
-		var buffer bytes.Buffer
-		cardName := fmt.Sprintf("%v-%v-%v.json", card.SetID, card.Release, card.ID)
-		dirName := filepath.Join(viper.GetString("cardDir"), lang.String(), card.SetID, card.Release)
+		dirParts := append([]string{cardDir, lang.String()}, cardPartition(card, viper.GetString("partition-by"))...)
+		filePath := filepath.Join(append(dirParts, cardName)...)
+		dirName := filepath.Dir(filePath)
 		os.MkdirAll(dirName, 0o744)
-		filePath := filepath.Join(dirName, cardName)
-		// Si le fichier existe et le flag force n'est pas activé, on skip la carte
+		idx.add(card.CardNumber, filepath.Join(append(dirParts[2:], cardName)...))
+		// Si le fichier existe et le flag force n'est pas activé, on fusionne
+		// avec la carte existante et on skip si rien n'a changé
 		if !viper.GetBool("force") {
-			if _, err := os.Stat(filePath); err == nil {
-				slog.Info(fmt.Sprintf("Skipping card (file exists): %v", cardName))
-				continue
+			if data, err := os.ReadFile(filePath); err == nil {
+				var existing fetch.Card
+				if err := json.Unmarshal(data, &existing); err != nil {
+					slog.Warn(fmt.Sprintf("Couldn't read existing card %v, overwriting: %v", cardName, err))
+				} else {
+					card = fetch.MergeCard(existing, card)
+					var merged bytes.Buffer
+					if err := fetch.WriteCardJSON(&merged, card); err == nil && bytes.Equal(compactJSON(data), compactJSON(merged.Bytes())) {
+						slog.Info(fmt.Sprintf("Skipping card (file exists): %v", cardName))
+						continue
+					}
+					slog.Info(fmt.Sprintf("Updating card: %v", cardName))
+				}
 			}
 		}
-		out, err := os.Create(filePath)
-		if err != nil {
+		var buffer bytes.Buffer
+		if err := fetch.WriteCardJSON(&buffer, card); err != nil {
+			slog.Error(fmt.Sprintf("error marshalling: %v", err))
+			continue
+		}
+		if err := os.WriteFile(filePath, buffer.Bytes(), 0o644); err != nil {
 			slog.Error(fmt.Sprintf("Error writing card: %v", err))
 			continue
 		}
-		json.Indent(&buffer, res, "", "\t")
-		buffer.WriteTo(out)
-		out.Close()
 		slog.Info(fmt.Sprintf("Finished card: %v", cardName))
 
 		// Téléchargement de l'image si l'option est activée
-		if viper.GetBool("images") && card.ImageURL != "" {
-			assetDir := filepath.Join(dirName, "assets")
-			os.MkdirAll(assetDir, 0o744)
+		if viper.GetBool("images") || viper.GetString("image-dir") != "" {
+			downloadCardImage(card, dirName, cfg)
+		}
+	}
+	wg.Done()
+}
 
-			// Supprimer les paramètres en analysant l'URL et en récupérant le chemin
-			parsedURL, err := url.Parse(card.ImageURL)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error parsing image URL %v: %v", card.ImageURL, err))
-				continue
-			}
-			imageName := filepath.Base(parsedURL.Path)
+// downloadCardImage downloads the image of card into the assets directory
+// next to its JSON in dirName, or into the flat --image-dir, with the clients
+// and image interval of cfg.
+func downloadCardImage(card fetch.Card, dirName string, cfg fetch.Config) {
+	if card.ImageURL == "" {
+		return
+	}
+	flatImageDir := viper.GetString("image-dir")
+	assetDir := filepath.Join(dirName, "assets")
+	if flatImageDir != "" {
+		assetDir = flatImageDir
+	}
+	os.MkdirAll(assetDir, 0o744)
 
-			imageFile := filepath.Join(assetDir, imageName)
-			if !viper.GetBool("force") {
-				if _, err := os.Stat(imageFile); err == nil {
-					slog.Info(fmt.Sprintf("Skipping image (file exists): %v", imageName))
-					continue
-				}
-			}
-			resp, err := http.Get(card.ImageURL)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error downloading image %v: %v", card.ImageURL, err))
-				continue
-			}
-			defer resp.Body.Close()
+	// Supprimer les paramètres en analysant l'URL et en récupérant le chemin
+	parsedURL, err := url.Parse(card.ImageURL)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error parsing image URL %v: %v", card.ImageURL, err))
+		return
+	}
+	imageName := filepath.Base(parsedURL.Path)
+	if flatImageDir != "" {
+		imageName = flatImageName(card.CardNumber, imageName)
+	}
 
-			outImg, err := os.Create(imageFile)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error creating image file %v: %v", imageName, err))
-				continue
-			}
-			_, err = io.Copy(outImg, resp.Body)
-			outImg.Close()
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error saving image %v: %v", imageName, err))
-			} else {
-				slog.Info(fmt.Sprintf("Downloaded image: %v", imageName))
-			}
+	imageFile := filepath.Join(assetDir, imageName)
+	if !viper.GetBool("force") {
+		if _, err := os.Stat(imageFile); err == nil {
+			slog.Info(fmt.Sprintf("Skipping image (file exists): %v", imageName))
+			return
 		}
 	}
-	wg.Done()
+	data, err := fetch.DownloadImage(card.ImageURL, cfg)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error downloading image %v: %v", card.ImageURL, err))
+		return
+	}
+	if err := os.WriteFile(imageFile, data, 0o644); err != nil {
+		slog.Error(fmt.Sprintf("Error saving image %v: %v", imageName, err))
+	} else {
+		slog.Info(fmt.Sprintf("Downloaded image: %v", imageName))
+	}
+}
+
+// downloadExistingImages downloads the images of the cards already written
+// under dir without scraping the card pages again.
+func downloadExistingImages(dir string, cfg fetch.Config) error {
+	return walkExistingCards(dir, func(card fetch.Card, path string) {
+		downloadCardImage(card, filepath.Dir(path), cfg)
+	})
+}
+
+// existingCards sums up the cards already written under a card directory.
+type existingCards struct {
+	// expansionCounts counts the cards by expansion number.
+	expansionCounts map[int]int
+	cardNumbers     map[string]bool
+	// withoutExpansion counts the cards written before Card.ExpansionID
+	// existed, which can't be matched to an expansion until fetched again.
+	withoutExpansion int
+}
+
+// scanExistingCards reads the cards already written under dir. A missing dir
+// has no cards.
+func scanExistingCards(dir string) (existingCards, error) {
+	existing := existingCards{
+		expansionCounts: make(map[int]int),
+		cardNumbers:     make(map[string]bool),
+	}
+	err := walkExistingCards(dir, func(card fetch.Card, path string) {
+		existing.cardNumbers[card.CardNumber] = true
+		if card.ExpansionID != 0 {
+			existing.expansionCounts[card.ExpansionID]++
+		} else {
+			existing.withoutExpansion++
+		}
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return existing, nil
+	}
+	return existing, err
+}
+
+// skipDownloadedExpansion returns a fetch.Config.SkipExpansion skipping the
+// expansions that already have at least ratio of their cards in counts. When
+// the site doesn't give the number of cards, any card counts as downloaded.
+func skipDownloadedExpansion(counts map[int]int, ratio float64) func(expansion, expected int) bool {
+	return func(expansion, expected int) bool {
+		have := counts[expansion]
+		if have == 0 {
+			return false
+		}
+		if expected == 0 {
+			return true
+		}
+		return float64(have) >= ratio*float64(expected)
+	}
+}
+
+// walkExistingCards calls fn with every card file written under dir.
+func walkExistingCards(dir string, fn func(card fetch.Card, path string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var card fetch.Card
+		if err := json.Unmarshal(data, &card); err != nil || card.CardNumber == "" {
+			slog.Debug(fmt.Sprintf("Skipping %v: not a card file", path))
+			return nil
+		}
+		fn(card, path)
+		return nil
+	})
+}
+
+// writeScrapeErrors writes the errors of a run to errors.json in dir.
+func writeScrapeErrors(dir string, errs []fetch.ScrapeError) error {
+	res, err := json.MarshalIndent(errs, "", "\t")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(dir, 0o744)
+	return os.WriteFile(filepath.Join(dir, "errors.json"), res, 0o644)
+}
+
+// outputExports are the export modes written to --output.
+var outputExports = []string{"jsonl", "imageurls", "deckformat", "cardnumbers", "bilingual"}
+
+// runConfigDir is where --emit-config writes the run config: next to the
+// --output file of the exports written there, else in cardDir.
+func runConfigDir(mode, output string) string {
+	if output != "-" && slices.Contains(outputExports, mode) {
+		return filepath.Dir(output)
+	}
+	return viper.GetString("cardDir")
+}
+
+// writeRunConfig writes the effective fetch config to name in dir so a
+// dataset can be traced back to what produced it.
+func writeRunConfig(dir, name string, cfg fetch.Config) error {
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(dir, 0o744)
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+// imageFileReplacer makes card numbers safe to use as file names.
+var imageFileReplacer = strings.NewReplacer("/", "_", "\\", "_", " ", "_")
+
+// flatImageName names the image of cardNumber in a flat --image-dir, keeping
+// the extension of the downloaded file.
+func flatImageName(cardNumber, downloaded string) string {
+	return imageFileReplacer.Replace(cardNumber) + filepath.Ext(downloaded)
+}
+
+// mergeBoosterFile merges cards into the booster already written at filename,
+// matching by card number. Fresh cards replace existing ones and new cards are
+// appended. If there's no usable file, cards is returned as is.
+func mergeBoosterFile(filename string, cards []fetch.Card) []fetch.Card {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return cards
+	}
+	var existing []fetch.Card
+	if err := json.Unmarshal(data, &existing); err != nil {
+		slog.Warn(fmt.Sprintf("Couldn't read existing booster %v, overwriting: %v", filename, err))
+		return cards
+	}
+	index := make(map[string]int, len(existing))
+	for i, c := range existing {
+		index[c.CardNumber] = i
+	}
+	added := 0
+	for _, c := range cards {
+		if i, ok := index[c.CardNumber]; ok {
+			existing[i] = c
+			continue
+		}
+		index[c.CardNumber] = len(existing)
+		existing = append(existing, c)
+		added++
+	}
+	slog.Info(fmt.Sprintf("Merged booster %v: %d new cards", filename, added))
+	return existing
 }
 
 func writeBoosters(lang language.Tag, boosters map[string]fetch.Booster) {
@@ -116,16 +393,399 @@ func writeBoosters(lang language.Tag, boosters map[string]fetch.Booster) {
 		dirName := filepath.Join(viper.GetString("boosterDir"), lang.String())
 		os.MkdirAll(dirName, 0o744)
 		filename := filepath.Join(dirName, k+".json")
-		updatedData, err := json.Marshal(v.Cards)
-		if err != nil {
+		cards := v.Cards
+		if !viper.GetBool("force") {
+			cards = mergeBoosterFile(filename, cards)
+		}
+		var updatedData bytes.Buffer
+		if err := fetch.WriteCardsJSON(&updatedData, cards); err != nil {
 			slog.Error("Error marshalling booster", "release", k, "error", err)
 		}
-		if err := os.WriteFile(filename, updatedData, 0o644); err != nil {
+		if err := os.WriteFile(filename, updatedData.Bytes(), 0o644); err != nil {
 			slog.Error(fmt.Sprintf("Error writing booster: %v", k))
 		}
 	}
 }
 
+// writeChecklists writes one CSV checklist per release into checklistDir.
+func writeChecklists(lang language.Tag, boosters map[string]fetch.Booster) {
+	dirName := filepath.Join(viper.GetString("checklistDir"), lang.String())
+	os.MkdirAll(dirName, 0o744)
+	for k, v := range boosters {
+		filename := filepath.Join(dirName, k+".csv")
+		if !viper.GetBool("force") {
+			if _, err := os.Stat(filename); err == nil {
+				slog.Info(fmt.Sprintf("Skipping checklist (file exists): %v", k))
+				continue
+			}
+		}
+		slog.Info(fmt.Sprintf("Writing checklist: %v", k))
+		out, err := os.Create(filename)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error writing checklist: %v", k))
+			continue
+		}
+		if err := fetch.WriteChecklistCSV(out, v.Cards); err != nil {
+			slog.Error(fmt.Sprintf("Error writing checklist %v: %v", k, err))
+		}
+		out.Close()
+	}
+}
+
+// openOutput opens the destination for stream exports. "-" means stdout.
+func openOutput(name string) (io.WriteCloser, error) {
+	if name == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if dir := filepath.Dir(name); dir != "." {
+		os.MkdirAll(dir, 0o744)
+	}
+	return os.Create(name)
+}
+
+// lazyOutput opens the --output destination on first use and keeps it open
+// until Close, so the expansions of an --expansions-file are all written to it
+// instead of each truncating it.
+type lazyOutput struct {
+	name string
+	w    io.WriteCloser
+}
+
+func (o *lazyOutput) open() (io.Writer, error) {
+	if o.w == nil {
+		w, err := openOutput(o.name)
+		if err != nil {
+			return nil, err
+		}
+		o.w = w
+	}
+	return o.w, nil
+}
+
+func (o *lazyOutput) Close() error {
+	if o.w == nil {
+		return nil
+	}
+	return o.w.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeCardsJSONL writes one JSON document per line as cards arrive. When
+// compress is set the stream is gzipped and flushed after every record so a
+// consumer on the other end of a pipe sees cards as soon as they're fetched.
+func writeCardsJSONL(out io.Writer, compress bool, cardCh <-chan fetch.Card) error {
+	w := out
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	err := fetch.WriteCardJSONL(w, cardCh)
+	if gz != nil {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func writeSets(lang language.Tag, sets map[string][]fetch.Card) {
+	writeCardGroups(filepath.Join(viper.GetString("setDir"), lang.String()), "set", sets)
+}
+
+func writeTraits(lang language.Tag, traits map[string][]fetch.Card) {
+	writeCardGroups(filepath.Join(viper.GetString("traitDir"), lang.String()), "trait", traits)
+}
+
+// groupFileReplacer makes group names like traits safe to use as file names.
+var groupFileReplacer = strings.NewReplacer("/", "_", "\\", "_")
+
+// writeCardGroups writes one JSON file per group into dirName. kind is only
+// used for logging.
+func writeCardGroups(dirName, kind string, groups map[string][]fetch.Card) {
+	os.MkdirAll(dirName, 0o744)
+	for name, cards := range groups {
+		filename := filepath.Join(dirName, groupFileReplacer.Replace(name)+".json")
+		if !viper.GetBool("force") {
+			if _, err := os.Stat(filename); err == nil {
+				slog.Info(fmt.Sprintf("Skipping %v (file exists): %v", kind, name))
+				continue
+			}
+		}
+		slog.Info(fmt.Sprintf("Writing %v: %v", kind, name))
+		out, err := os.Create(filename)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error writing %v: %v", kind, name))
+			continue
+		}
+		if err := fetch.WriteCardsJSON(out, cards); err != nil {
+			slog.Error(fmt.Sprintf("Error marshalling %v", kind), kind, name, "error", err)
+		}
+		out.Close()
+	}
+}
+
+// printProgress draws a one line progress indicator on stderr so it doesn't
+// end up in stdout exports.
+func printProgress(p fetch.Progress) {
+	if p.TotalPages == 0 {
+		fmt.Fprintf(os.Stderr, "\rpages %d/? cards %d", p.PagesScanned, p.Cards)
+		return
+	}
+	const width = 30
+	filled := min(width*p.PagesScanned/p.TotalPages, width)
+	fmt.Fprintf(os.Stderr, "\r[%s%s] pages %d/%d cards %d",
+		strings.Repeat("#", filled), strings.Repeat(" ", width-filled),
+		p.PagesScanned, p.TotalPages, p.Cards)
+}
+
+// printNumberedList prints the entries of m sorted by number under header.
+func printNumberedList(header string, m map[int]string) {
+	if len(m) == 0 {
+		return
+	}
+	var numbers []int
+	for n := range m {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	fmt.Printf("%s:\n", header)
+	for _, n := range numbers {
+		fmt.Printf("\t%d: %s\n", n, m[n])
+	}
+}
+
+// runExport fetches the cards for cfg and writes them out in the given mode.
+// Stream exports write to output.
+func runExport(mode string, cfg fetch.Config, lang language.Tag, writers int, output *lazyOutput) {
+	slog.Info(fmt.Sprintf("Start write in mode: %v", mode))
+	switch mode {
+	case "booster":
+		bm, err := fetch.Boosters(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching boosters: %v", err))
+		}
+		writeBoosters(lang, bm)
+	case "checklist":
+		bm, err := fetch.Boosters(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching boosters: %v", err))
+		}
+		writeChecklists(lang, bm)
+	case "card":
+		cardCh := make(chan fetch.Card, writers)
+		tmpl, err := parseFilenameTemplate(viper.GetString("filename-template"))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Invalid filename template: %v", err))
+			return
+		}
+		var idx *cardIndex
+		if viper.GetBool("index") {
+			idx = newCardIndex()
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go writeCards(&wg, viper.GetString("cardDir"), lang, cfg, idx, tmpl, cardCh)
+		}
+		stats, err := fetch.CardsStreamWithStats(cfg, cardCh)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		wg.Wait()
+		outDir := filepath.Join(viper.GetString("cardDir"), lang.String())
+		if idx != nil {
+			if err := idx.write(outDir); err != nil {
+				slog.Error(fmt.Sprintf("Error writing index: %v", err))
+			}
+		}
+		if len(stats.Errors) > 0 {
+			if err := writeScrapeErrors(outDir, stats.Errors); err != nil {
+				slog.Error(fmt.Sprintf("Error writing errors.json: %v", err))
+			} else {
+				slog.Warn(fmt.Sprintf("%d pages or cards failed, see errors.json", len(stats.Errors)))
+			}
+		}
+	case "jsonl":
+		out, err := output.open()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening output: %v", err))
+			return
+		}
+		cardCh := make(chan fetch.Card, writers)
+		done := make(chan error)
+		go func() {
+			done <- writeCardsJSONL(out, viper.GetBool("compress"), cardCh)
+		}()
+		if err := fetch.CardsStream(cfg, cardCh); err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		if err := <-done; err != nil {
+			slog.Error(fmt.Sprintf("Error finishing output: %v", err))
+		}
+	case "kv":
+		store, err := fetch.OpenStore(viper.GetString("kvFile"))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening store: %v", err))
+			return
+		}
+		defer store.Close()
+		cardCh := make(chan fetch.Card, writers)
+		done := make(chan error)
+		go func() {
+			done <- fetch.WriteCardStore(store, viper.GetBool("force"), cardCh)
+		}()
+		if err := fetch.CardsStream(cfg, cardCh); err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		if err := <-done; err != nil {
+			slog.Error(fmt.Sprintf("Error finishing store: %v", err))
+		}
+	case "setfiles":
+		sets, err := fetch.Sets(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching sets: %v", err))
+		}
+		writeSets(lang, sets)
+	case "traits":
+		traits, err := fetch.Traits(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching traits: %v", err))
+		}
+		writeTraits(lang, traits)
+	case "imageurls":
+		urls, err := fetch.ImageURLs(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		out, err := output.open()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening output: %v", err))
+			return
+		}
+		for _, u := range urls {
+			fmt.Fprintln(out, u)
+		}
+	case "deckformat":
+		cards, err := fetch.Cards(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		sort.Slice(cards, func(i, j int) bool { return cards[i].CardNumber < cards[j].CardNumber })
+		deckCards := make([]fetch.DeckCard, 0, len(cards))
+		for _, c := range cards {
+			deckCards = append(deckCards, fetch.ToDeckCard(c))
+		}
+		out, err := output.open()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening output: %v", err))
+			return
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(deckCards); err != nil {
+			slog.Error(fmt.Sprintf("Error writing deck format: %v", err))
+		}
+	case "cardnumbers":
+		numbers, err := fetch.CardNumbers(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+		}
+		out, err := output.open()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening output: %v", err))
+			return
+		}
+		for _, n := range numbers {
+			fmt.Fprintln(out, n)
+		}
+	case "bilingual":
+		cardDir := viper.GetString("cardDir")
+		en, err := loadCardDir(filepath.Join(cardDir, language.English.String()))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error reading EN cards: %v", err))
+			return
+		}
+		jp, err := loadCardDir(filepath.Join(cardDir, language.Japanese.String()))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error reading JP cards: %v", err))
+			return
+		}
+		out, err := output.open()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error opening output: %v", err))
+			return
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(fetch.JoinLanguages(en, jp)); err != nil {
+			slog.Error(fmt.Sprintf("Error writing bilingual cards: %v", err))
+		}
+	case "expansionlist":
+		eMap, err := fetch.ExpansionList(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching expansion list: %v", err))
+		}
+		printNumberedList("Expansions", eMap)
+	case "titlelist":
+		tMap, err := fetch.TitleList(cfg)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error fetching title list: %v", err))
+		}
+		printNumberedList("Titles", tMap)
+	default:
+		panic(fmt.Sprintf("Unsupported export mode: %q", mode))
+	}
+}
+
+// expansionEntry is an expansion to fetch from an --expansions-file.
+type expansionEntry struct {
+	Number int    `mapstructure:"number"`
+	Lang   string `mapstructure:"lang"`
+}
+
+// readExpansionsFile reads the "expansions" list from a YAML or JSON file.
+func readExpansionsFile(name string) ([]expansionEntry, error) {
+	v := viper.New()
+	v.SetConfigFile(name)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("couldn't read expansions file: %v", err)
+	}
+	var entries []expansionEntry
+	if err := v.UnmarshalKey("expansions", &entries); err != nil {
+		return nil, fmt.Errorf("couldn't parse expansions file: %v", err)
+	}
+	return entries, nil
+}
+
+// parseSiteLanguage maps a language parameter to the site to fetch from.
+func parseSiteLanguage(s string) (language.Tag, fetch.SiteLanguage) {
+	lang, err := language.Parse(s)
+	if err != nil {
+		panic(fmt.Errorf("invalid language parameter: %v", err))
+	}
+
+	lBase, conf := language.Tag(lang).Base()
+	if conf == language.No {
+		panic(fmt.Errorf("completely unknown language: %v", lang))
+	} else if conf != language.Exact {
+		slog.Info(fmt.Sprintf("Checking base language %v with confidence %v", lBase, conf))
+	}
+	switch lBase.String() {
+	case language.English.String():
+		return lang, fetch.English
+	case language.Japanese.String():
+		return lang, fetch.Japanese
+	default:
+		panic(fmt.Sprintf("Unsupported language: %v", lang))
+	}
+}
+
 // fetchCmd represents the fetch command
 var fetchCmd = &cobra.Command{
 	Use:   "fetch",
@@ -134,31 +794,58 @@ var fetchCmd = &cobra.Command{
 
 Use global switches to specify the set, by default it will fetch all sets.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := fetch.Config{
-			GetAllRarities: viper.GetBool("allrarity"),
-			GetRecent:      viper.GetBool("recent"),
-			PageStart:      viper.GetInt("pagestart"),
-			Reverse:        viper.GetBool("reverse"),
+		var colors []string
+		for _, c := range viper.GetStringSlice("color") {
+			colors = append(colors, strings.ToUpper(strings.TrimSpace(c)))
 		}
-		lang, err := language.Parse(viper.GetString("lang"))
-		if err != nil {
-			panic(fmt.Errorf("invalid language parameter: %v", err))
+		var types []string
+		for _, t := range viper.GetStringSlice("type") {
+			types = append(types, strings.ToUpper(strings.TrimSpace(t)))
 		}
-
-		lBase, conf := language.Tag(lang).Base()
-		if conf == language.No {
-			panic(fmt.Errorf("completely unknown language: %v", cfg.Language))
-		} else if conf != language.Exact {
-			slog.Info(fmt.Sprintf("Checking base language %v with confidence %v", lBase, conf))
+		var triggerLabels map[string]string
+		for _, l := range viper.GetStringSlice("trigger-label") {
+			name, label, ok := strings.Cut(l, "=")
+			if !ok {
+				panic(fmt.Sprintf("Invalid trigger label %q: expected TRIGGER=LABEL", l))
+			}
+			if triggerLabels == nil {
+				triggerLabels = make(map[string]string)
+			}
+			triggerLabels[strings.ToUpper(strings.TrimSpace(name))] = strings.TrimSpace(label)
 		}
-		switch lBase.String() {
-		case language.English.String():
-			cfg.Language = fetch.English
-		case language.Japanese.String():
-			cfg.Language = fetch.Japanese
-		default:
-			panic(fmt.Sprintf("Unsupported language: %v", lang))
+		cfg := fetch.Config{
+			Colors:                 colors,
+			ComputeSearchName:      viper.GetBool("searchname"),
+			DirectConnection:       viper.GetBool("direct"),
+			DropDuplicates:         viper.GetBool("dedupe"),
+			GetAllRarities:         viper.GetBool("allrarity"),
+			GetRecent:              viper.GetBool("recent"),
+			ExtractKeywords:        viper.GetBool("keywords"),
+			ForceCardVersion:       viper.GetString("card-version"),
+			ImageBaseURL:           viper.GetString("image-base-url"),
+			ImageRequestInterval:   viper.GetDuration("image-interval"),
+			IncludeReleaseDate:     viper.GetBool("releasedate"),
+			KeywordMode:            viper.GetString("keywordmode"),
+			LabelTriggers:          viper.GetBool("label-triggers"),
+			MaxDuration:            viper.GetDuration("max-duration"),
+			MaxConsecutiveFailures: viper.GetInt("max-failures"),
+			PageStart:              viper.GetInt("pagestart"),
+			PanicOnExtractError:    viper.GetBool("panic-on-extract-error"),
+			ParseCosts:             viper.GetBool("costs"),
+			ProxyWaitTimeout:       viper.GetDuration("proxywait"),
+			RandSeed:               viper.GetInt64("seed"),
+			RespectRobotsTxt:       viper.GetBool("polite"),
+			RetryTargets:           viper.GetStringSlice("retry"),
+			Reverse:                viper.GetBool("reverse"),
+			Side:                   strings.ToUpper(viper.GetString("side")),
+			SplitSetCodes:          viper.GetBool("split-neo"),
+			TriggerLabels:          triggerLabels,
+			Types:                  types,
+			UseGET:                 viper.GetBool("get"),
+			UseSitemap:             viper.GetBool("sitemap"),
 		}
+		var lang language.Tag
+		lang, cfg.Language = parseSiteLanguage(viper.GetString("lang"))
 		if serieNumber != "" {
 			if s, err := strconv.Atoi(serieNumber); err == nil {
 				cfg.ExpansionNumber = s
@@ -179,47 +866,75 @@ Use global switches to specify the set, by default it will fetch all sets.`,
 
 		slog.Debug("fetch called", "settings", viper.AllSettings())
 
-		mode := viper.GetString("export")
-		slog.Info(fmt.Sprintf("Start write in mode: %v", mode))
-		switch mode {
-		case "booster":
-			bm, err := fetch.Boosters(cfg)
+		if scheme := viper.GetString("partition-by"); !slices.Contains(partitionSchemes, scheme) {
+			panic(fmt.Sprintf("Unsupported partition scheme: %q, expected one of %v", scheme, partitionSchemes))
+		}
+
+		writers := maxWorker
+		if w := viper.GetInt("workers"); w > 0 {
+			cfg.Workers = w
+			writers = w
+		}
+
+		if viper.GetBool("progress") {
+			cfg.OnProgress = printProgress
+			defer fmt.Fprintln(os.Stderr)
+		}
+
+		if viper.GetBool("images-only") {
+			if err := downloadExistingImages(filepath.Join(viper.GetString("cardDir"), lang.String()), cfg); err != nil {
+				slog.Error(fmt.Sprintf("Error reading existing cards: %v", err))
+			}
+			return
+		}
+
+		if viper.GetBool("only-new") {
+			existing, err := scanExistingCards(filepath.Join(viper.GetString("cardDir"), lang.String()))
 			if err != nil {
-				slog.Error(fmt.Sprintf("Error fetching boosters: %v", err))
+				slog.Error(fmt.Sprintf("Error reading existing cards: %v", err))
+				return
 			}
-			writeBoosters(lang, bm)
-		case "card":
-			cardCh := make(chan fetch.Card, maxWorker)
-			var wg sync.WaitGroup
-			for i := 0; i < maxWorker; i++ {
-				wg.Add(1)
-				go writeCards(&wg, lang, cardCh)
+			if existing.withoutExpansion > 0 {
+				slog.Warn(fmt.Sprintf("%d existing cards have no expansion number and can't make --only-new skip their expansion, fetch them again without --only-new to add it", existing.withoutExpansion))
 			}
-			err := fetch.CardsStream(cfg, cardCh)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error fetching cards: %v", err))
+			cfg.SkipExpansion = skipDownloadedExpansion(existing.expansionCounts, viper.GetFloat64("only-new-ratio"))
+			// Within the expansions still fetched, only the missing cards are.
+			cfg.SkipCardNumber = func(cardNumber string) bool {
+				return existing.cardNumbers[cardNumber]
 			}
-			wg.Wait()
-		case "expansionlist":
-			eMap, err := fetch.ExpansionList(cfg)
+		}
+
+		mode := viper.GetString("export")
+		emitConfig := func(name string, cfg fetch.Config) {
+			if !viper.GetBool("emit-config") {
+				return
+			}
+			if err := writeRunConfig(runConfigDir(mode, viper.GetString("output")), name, cfg); err != nil {
+				slog.Error(fmt.Sprintf("Error writing run config: %v", err))
+			}
+		}
+		output := &lazyOutput{name: viper.GetString("output")}
+		defer output.Close()
+		if expansionsFile := viper.GetString("expansions-file"); expansionsFile != "" {
+			entries, err := readExpansionsFile(expansionsFile)
 			if err != nil {
-				slog.Error(fmt.Sprintf("Error fetching expansion list: %v", err))
+				slog.Error(err.Error())
+				return
 			}
-			if len(eMap) > 0 {
-				var expansions []int
-				for e := range eMap {
-					expansions = append(expansions, e)
-				}
-				sort.Ints(expansions)
-				fmt.Println("Expansions:")
-				for _, e := range expansions {
-
-					fmt.Printf("\t%d: %s\n", e, eMap[e])
+			for _, e := range entries {
+				entryCfg, entryLang := cfg, lang
+				entryCfg.ExpansionNumber = e.Number
+				if e.Lang != "" {
+					entryLang, entryCfg.Language = parseSiteLanguage(e.Lang)
 				}
+				slog.Info(fmt.Sprintf("Fetching expansion %d (%v)", e.Number, entryLang))
+				emitConfig(fmt.Sprintf("run-config-%v-%d.json", entryLang, e.Number), entryCfg)
+				runExport(mode, entryCfg, entryLang, writers, output)
 			}
-		default:
-			panic(fmt.Sprintf("Unsupported export mode: %q", mode))
+			return
 		}
+		emitConfig("run-config.json", cfg)
+		runExport(mode, cfg, lang, writers, output)
 	},
 }
 
@@ -237,17 +952,64 @@ func init() {
 	// fetchCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	fetchCmd.Flags().StringP("boosterDir", "", "boosters", "Directory to put fetched booster information into")
 	fetchCmd.Flags().StringP("cardDir", "d", "cards", "Directory to put fetched card information into")
+	fetchCmd.Flags().String("setDir", "sets", "Directory to put fetched set files into")
+	fetchCmd.Flags().String("checklistDir", "checklists", "Directory to put the checklist CSV files into")
+	fetchCmd.Flags().String("traitDir", "traits", "Directory to put fetched trait files into")
+	fetchCmd.Flags().String("kvFile", "cards.db", "BoltDB file to store cards into with the kv export")
 	fetchCmd.Flags().IntP("pagestart", "p", 0, "Start scanning from page #. Skip everything else before this page")
 	fetchCmd.Flags().BoolP("reverse", "r", false, "Reverse order")
 	fetchCmd.Flags().BoolP("allrarity", "a", true, "get all rarity (sp, ssp, sbr, etc...)")
-	fetchCmd.Flags().StringP("export", "e", "card", "export value: card, booster, expansionlist, all")
+	fetchCmd.Flags().StringP("export", "e", "card", "export value: card, booster, checklist, setfiles, traits, jsonl, kv, deckformat, imageurls, cardnumbers, bilingual (joins the en and ja cards already in cardDir), expansionlist, titlelist, all")
 	fetchCmd.Flags().String("lang", "ja", "Site language to pull from. Options are en or ja.")
 	fetchCmd.Flags().BoolP("recent", "", false, "get all recent products")
 	fetchCmd.Flags().BoolP("force", "f", false, "Force rewriting of files even if they already exist")
 	fetchCmd.Flags().Bool("images", false, "Télécharge les images et les place dans un dossier assets à coté des json")
+	fetchCmd.Flags().Bool("images-only", false, "Only download the images of the cards already in cardDir, without fetching the cards")
+	fetchCmd.Flags().Bool("only-new", false, "Skip the expansions (e.g. with --recent) whose cards are already in cardDir, and the cards already there in the others. Cards written before the expansion number was saved don't count towards skipping an expansion")
+	fetchCmd.Flags().Float64("only-new-ratio", 1, "Share of an expansion's cards that must be in cardDir for --only-new to skip it")
+	fetchCmd.Flags().String("image-dir", "", "Download the images into this flat directory, named by card number, instead of the assets directories")
+	fetchCmd.Flags().Duration("image-interval", 0, "Minimum time between two image requests of a worker, 0 uses the page interval")
+	fetchCmd.Flags().String("keywordmode", fetch.KeywordOr, "How EN set codes given with --neo are matched: or, and")
+	fetchCmd.Flags().Bool("split-neo", false, "Run a separate search for each set code given with --neo")
+	fetchCmd.Flags().Bool("emit-config", false, "Write the effective fetch config to run-config.json in cardDir, or next to the --output file. With --expansions-file, one run-config-LANG-NUMBER.json per expansion")
+	fetchCmd.Flags().Bool("polite", false, "Obey the site's robots.txt and skip the pages it disallows")
+	fetchCmd.Flags().Bool("sitemap", false, "Fetch the cards listed in the site's sitemap instead of paginating the search, if there is one")
+	fetchCmd.Flags().String("expansions-file", "", "YAML or JSON file with a list of expansions (number, lang) to fetch one after another")
+	fetchCmd.Flags().Bool("releasedate", false, "Add the release date to cards (ja only, needs extra requests)")
+	fetchCmd.Flags().Bool("keywords", false, "Add the keyword abilities found in the text (Alarm, Encore, ...) to cards")
+	fetchCmd.Flags().Bool("costs", false, "Add the parsed ability costs (stock, rest, discard, clock) to cards")
+	fetchCmd.Flags().StringSlice("trigger-label", nil, "Write a trigger differently in the ability text, e.g. SOUL=S,GATE=G")
+	fetchCmd.Flags().Bool("label-triggers", false, "Use the --trigger-label labels in the card triggers too")
+	fetchCmd.Flags().Bool("searchname", false, "Add a normalized searchName field to cards")
+	fetchCmd.Flags().StringSlice("color", nil, "Only keep cards of these colors: blue, green, red, yellow, purple")
+	fetchCmd.Flags().StringSlice("type", nil, "Only keep cards of these types: ch, ev, cx. A single type also filters the JP search")
+	fetchCmd.Flags().String("side", "", "Only keep cards of one side: W or S")
+	fetchCmd.Flags().String("image-base-url", "", "Replace the host of image URLs, e.g. to use a mirror")
+	fetchCmd.Flags().String("card-version", "", "Stamp cards with this model version instead of the current one (for migration fixtures only)")
+	fetchCmd.Flags().MarkHidden("card-version")
+	fetchCmd.Flags().StringSlice("retry", nil, "Card numbers or detail page URLs to refetch instead of searching")
+	fetchCmd.Flags().Bool("direct", false, "Don't use proxies for the expansion list")
+	fetchCmd.Flags().Bool("get", false, "Send the searches as GET requests so caching proxies can cache them (check with doctor --get first)")
+	fetchCmd.Flags().Bool("dedupe", false, "Drop cards seen twice in the same scrape or booster instead of only warning")
+	fetchCmd.Flags().Bool("index", false, "Also write an index.json mapping card numbers to their file (card export)")
+	fetchCmd.Flags().String("partition-by", "setrelease", "How to lay out card files under cardDir: setrelease, rarity, type, color")
+	fetchCmd.Flags().String("filename-template", defaultFilenameTemplate, "Go template of the card file names, with the Card fields, e.g. '{{.SetID}}/{{.CardNumber}}.json'. / in values becomes _")
+	fetchCmd.Flags().StringP("output", "o", "-", "Output file for stream exports (jsonl, deckformat, imageurls, cardnumbers, bilingual). Use - for stdout")
+	fetchCmd.Flags().Bool("compress", false, "Gzip the output of stream exports (jsonl)")
+	fetchCmd.Flags().IntP("workers", "w", 0, "Number of concurrent workers for fetching and writing (0 uses the defaults)")
+	fetchCmd.Flags().Bool("panic-on-extract-error", false, "Crash on the first card that fails to parse instead of logging it")
+	fetchCmd.Flags().Int64("seed", 0, "Seed for the random retry and start jitter, for reproducible runs (0 is random)")
+	fetchCmd.Flags().Int("max-failures", 0, "Abort after this many page fetches fail in a row (0 never aborts)")
+	fetchCmd.Flags().Duration("max-duration", 0, "Stop fetching new pages after this long and keep what was fetched (0 is no limit)")
+	fetchCmd.Flags().Bool("progress", false, "Show a progress indicator on stderr")
+	fetchCmd.Flags().Duration("proxywait", 2*time.Minute, "How long to wait for a usable proxy before giving up (0 waits forever)")
 
 	viper.BindPFlag("boosterDir", fetchCmd.Flags().Lookup("boosterDir"))
 	viper.BindPFlag("cardDir", fetchCmd.Flags().Lookup("cardDir"))
+	viper.BindPFlag("setDir", fetchCmd.Flags().Lookup("setDir"))
+	viper.BindPFlag("traitDir", fetchCmd.Flags().Lookup("traitDir"))
+	viper.BindPFlag("checklistDir", fetchCmd.Flags().Lookup("checklistDir"))
+	viper.BindPFlag("kvFile", fetchCmd.Flags().Lookup("kvFile"))
 	viper.BindPFlag("pagestart", fetchCmd.Flags().Lookup("pagestart"))
 	viper.BindPFlag("reverse", fetchCmd.Flags().Lookup("reverse"))
 	viper.BindPFlag("allrarity", fetchCmd.Flags().Lookup("allrarity"))
@@ -256,4 +1018,42 @@ func init() {
 	viper.BindPFlag("recent", fetchCmd.Flags().Lookup("recent"))
 	viper.BindPFlag("force", fetchCmd.Flags().Lookup("force"))
 	viper.BindPFlag("images", fetchCmd.Flags().Lookup("images"))
+	viper.BindPFlag("images-only", fetchCmd.Flags().Lookup("images-only"))
+	viper.BindPFlag("only-new", fetchCmd.Flags().Lookup("only-new"))
+	viper.BindPFlag("only-new-ratio", fetchCmd.Flags().Lookup("only-new-ratio"))
+	viper.BindPFlag("image-dir", fetchCmd.Flags().Lookup("image-dir"))
+	viper.BindPFlag("image-interval", fetchCmd.Flags().Lookup("image-interval"))
+	viper.BindPFlag("keywordmode", fetchCmd.Flags().Lookup("keywordmode"))
+	viper.BindPFlag("split-neo", fetchCmd.Flags().Lookup("split-neo"))
+	viper.BindPFlag("emit-config", fetchCmd.Flags().Lookup("emit-config"))
+	viper.BindPFlag("polite", fetchCmd.Flags().Lookup("polite"))
+	viper.BindPFlag("sitemap", fetchCmd.Flags().Lookup("sitemap"))
+	viper.BindPFlag("expansions-file", fetchCmd.Flags().Lookup("expansions-file"))
+	viper.BindPFlag("releasedate", fetchCmd.Flags().Lookup("releasedate"))
+	viper.BindPFlag("keywords", fetchCmd.Flags().Lookup("keywords"))
+	viper.BindPFlag("costs", fetchCmd.Flags().Lookup("costs"))
+	viper.BindPFlag("trigger-label", fetchCmd.Flags().Lookup("trigger-label"))
+	viper.BindPFlag("label-triggers", fetchCmd.Flags().Lookup("label-triggers"))
+	viper.BindPFlag("searchname", fetchCmd.Flags().Lookup("searchname"))
+	viper.BindPFlag("color", fetchCmd.Flags().Lookup("color"))
+	viper.BindPFlag("type", fetchCmd.Flags().Lookup("type"))
+	viper.BindPFlag("side", fetchCmd.Flags().Lookup("side"))
+	viper.BindPFlag("image-base-url", fetchCmd.Flags().Lookup("image-base-url"))
+	viper.BindPFlag("card-version", fetchCmd.Flags().Lookup("card-version"))
+	viper.BindPFlag("retry", fetchCmd.Flags().Lookup("retry"))
+	viper.BindPFlag("direct", fetchCmd.Flags().Lookup("direct"))
+	viper.BindPFlag("get", fetchCmd.Flags().Lookup("get"))
+	viper.BindPFlag("dedupe", fetchCmd.Flags().Lookup("dedupe"))
+	viper.BindPFlag("panic-on-extract-error", fetchCmd.Flags().Lookup("panic-on-extract-error"))
+	viper.BindPFlag("index", fetchCmd.Flags().Lookup("index"))
+	viper.BindPFlag("partition-by", fetchCmd.Flags().Lookup("partition-by"))
+	viper.BindPFlag("filename-template", fetchCmd.Flags().Lookup("filename-template"))
+	viper.BindPFlag("output", fetchCmd.Flags().Lookup("output"))
+	viper.BindPFlag("compress", fetchCmd.Flags().Lookup("compress"))
+	viper.BindPFlag("workers", fetchCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("seed", fetchCmd.Flags().Lookup("seed"))
+	viper.BindPFlag("max-failures", fetchCmd.Flags().Lookup("max-failures"))
+	viper.BindPFlag("max-duration", fetchCmd.Flags().Lookup("max-duration"))
+	viper.BindPFlag("progress", fetchCmd.Flags().Lookup("progress"))
+	viper.BindPFlag("proxywait", fetchCmd.Flags().Lookup("proxywait"))
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kwadkore/ws-scraper/fetch"
+	"github.com/spf13/viper"
+)
+
+func TestCardFileName(t *testing.T) {
+	card := fetch.Card{
+		CardNumber: "BD/W63-036SPMa",
+		SetID:      "BD",
+		Release:    "W63",
+		ID:         "036SPMa",
+		Name:       "..",
+	}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{"default", defaultFilenameTemplate, "BD-W63-036SPMa.json", false},
+		{"card number", "{{.CardNumber}}.json", "BD_W63-036SPMa.json", false},
+		{"subdirectory", "{{.SetID}}/{{.CardNumber}}.json", filepath.Join("BD", "BD_W63-036SPMa.json"), false},
+		{"parent directory", "../{{.ID}}.json", "", true},
+		{"absolute", "/tmp/{{.ID}}.json", "", true},
+		{"traversing value", "{{.Name}}", "", true},
+		{"unknown field", "{{.Nope}}.json", "", true},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseFilenameTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("%v: couldn't parse %q: %v", tt.name, tt.template, err)
+		}
+		got, err := cardFileName(tmpl, card)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: got %q: expected an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: got unexpected error: %v", tt.name, err)
+		} else if got != tt.expected {
+			t.Errorf("%v: got %q: expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestScanExistingCards(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"BD/W63/BD-W63-001.json": `{"cardNumber":"BD/W63-001","expansionId":159}`,
+		"BD/W63/BD-W63-002.json": `{"cardNumber":"BD/W63-002","expansionId":159}`,
+		"BD/W54/BD-W54-001.json": `{"cardNumber":"BD/W54-001"}`,
+		"index.json":             `{"BD/W63-001":"BD/W63/BD-W63-001.json"}`,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	existing, err := scanExistingCards(dir)
+	if err != nil {
+		t.Fatal("Got unexpected error:", err)
+	}
+	if existing.expansionCounts[159] != 2 || len(existing.expansionCounts) != 1 {
+		t.Errorf("Got expansion counts %v: expected 2 cards of 159", existing.expansionCounts)
+	}
+	if existing.withoutExpansion != 1 {
+		t.Errorf("Got %d cards without expansion: expected 1", existing.withoutExpansion)
+	}
+	for _, cn := range []string{"BD/W63-001", "BD/W63-002", "BD/W54-001"} {
+		if !existing.cardNumbers[cn] {
+			t.Errorf("Card %v not found", cn)
+		}
+	}
+
+	if _, err := scanExistingCards(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("Missing dir: got unexpected error: %v", err)
+	}
+}
+
+func TestRunConfigDir(t *testing.T) {
+	viper.Set("cardDir", "cards")
+	defer viper.Set("cardDir", nil)
+
+	tests := []struct {
+		mode, output, expected string
+	}{
+		{"card", "-", "cards"},
+		{"card", filepath.Join("out", "cards.jsonl"), "cards"},
+		{"jsonl", "-", "cards"},
+		{"jsonl", filepath.Join("out", "cards.jsonl"), "out"},
+		{"deckformat", "deck.json", "."},
+	}
+	for _, tt := range tests {
+		if got := runConfigDir(tt.mode, tt.output); got != tt.expected {
+			t.Errorf("runConfigDir(%q, %q) = %q: expected %q", tt.mode, tt.output, got, tt.expected)
+		}
+	}
+}
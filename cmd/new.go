@@ -0,0 +1,111 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+
+	"github.com/kwadkore/ws-scraper/fetch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Fetch the recent cards missing from cardDir",
+	Long: `Fetch the cards of the recent releases that aren't in cardDir yet.
+
+The card numbers already in cardDir are skipped before their page is fetched
+when the site allows it, and only the new cards are written. With --dry-run the
+new card numbers are printed instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardDir := viper.GetString("new.cardDir")
+		dryRun := viper.GetBool("new.dry-run")
+		lang, siteLang := parseSiteLanguage(viper.GetString("new.lang"))
+		tmpl, err := parseFilenameTemplate(viper.GetString("new.filename-template"))
+		if err != nil {
+			return fmt.Errorf("invalid filename template: %v", err)
+		}
+
+		dir := filepath.Join(cardDir, lang.String())
+		existing, err := loadCardDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to load %v: %v", dir, err)
+		}
+		have := make(map[string]bool, len(existing))
+		for _, card := range existing {
+			have[card.CardNumber] = true
+		}
+
+		cfg := fetch.Config{
+			Language:  siteLang,
+			GetRecent: true,
+			SkipCardNumber: func(cardNumber string) bool {
+				return have[cardNumber]
+			},
+		}
+		cardCh := make(chan fetch.Card, maxWorker)
+		newCh := make(chan fetch.Card, maxWorker)
+		var wg sync.WaitGroup
+		if !dryRun {
+			for i := 0; i < maxWorker; i++ {
+				wg.Add(1)
+				go writeCards(&wg, cardDir, lang, cfg, nil, tmpl, newCh)
+			}
+		}
+
+		added := 0
+		done := make(chan struct{})
+		go func() {
+			for card := range cardCh {
+				added++
+				if dryRun {
+					fmt.Println(card.CardNumber)
+				} else {
+					newCh <- card
+				}
+			}
+			close(newCh)
+			close(done)
+		}()
+		err = fetch.CardsStream(cfg, cardCh)
+		<-done
+		wg.Wait()
+
+		fmt.Printf("%d new cards in %v (%d already there)\n", added, dir, len(have))
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+
+	newCmd.Flags().String("lang", "ja", "Site language to pull from. Options are en or ja.")
+	newCmd.Flags().StringP("cardDir", "d", "cards", "Directory with the cards already fetched, where the new ones are written")
+	newCmd.Flags().Bool("dry-run", false, "Print the new card numbers instead of writing them")
+	newCmd.Flags().String("filename-template", defaultFilenameTemplate, "Go template of the card file names, the same as fetch's --filename-template")
+
+	// The keys are prefixed so they don't replace the fetch flags bound to
+	// the same names.
+	viper.BindPFlag("new.lang", newCmd.Flags().Lookup("lang"))
+	viper.BindPFlag("new.cardDir", newCmd.Flags().Lookup("cardDir"))
+	viper.BindPFlag("new.dry-run", newCmd.Flags().Lookup("dry-run"))
+	viper.BindPFlag("new.filename-template", newCmd.Flags().Lookup("filename-template"))
+}
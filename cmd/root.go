@@ -30,6 +30,8 @@ var (
 	serieNumber string
 	titleNumber string
 	neo         string
+	verbose     bool
+	quiet       bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -60,24 +62,27 @@ To use environ variable, use the prefix 'WSOFF'.
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) {
 	// },
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		switch logLevel {
-		case "d", "debug":
-			slog.Info("Setting log level to debug")
-			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
-		case "e", "error":
-			slog.Info("Setting log level to error")
-			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
-		default:
-			fallthrough
-		case "i", "info":
-			slog.Info("Setting log level to info")
-			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
-		case "w", "warn":
-			slog.Info("Setting log level to warn")
-			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
-		}
-	},
+}
+
+// setLogLevel installs the default slog handler with the level asked for on
+// the command line. --verbose and --quiet win over --log.
+func setLogLevel() {
+	level := slog.LevelInfo
+	switch logLevel {
+	case "d", "debug":
+		level = slog.LevelDebug
+	case "w", "warn":
+		level = slog.LevelWarn
+	case "e", "error":
+		level = slog.LevelError
+	}
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelWarn
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	slog.Debug(fmt.Sprintf("Log level set to %v", level))
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -96,6 +101,9 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log", "l", "i", "Minimum log level to allow. One of d|debug|i|info|w|warn|e|error")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show debug logs (same as --log debug)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only show warnings and errors (same as --log warn)")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 	rootCmd.PersistentFlags().StringVarP(&serieNumber, "expansion", "", "", "expansion number")
 	rootCmd.PersistentFlags().StringVarP(&titleNumber, "title", "t", "", "title number")
 	rootCmd.PersistentFlags().StringVarP(&neo, "neo", "n", "", "Neo standar by set")
@@ -103,6 +111,9 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	// Set the level first so the config file messages respect it.
+	setLogLevel()
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -0,0 +1,89 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// BilingualCard is the EN and JP versions of a card side by side. A card
+// only printed in one language has the other side empty.
+type BilingualCard struct {
+	// Key is the language independent card number both versions share, see
+	// LanguageKey.
+	Key          string   `json:"key"`
+	CardNumberEN string   `json:"cardNumberEN,omitempty"`
+	CardNumberJP string   `json:"cardNumberJP,omitempty"`
+	NameEN       string   `json:"nameEN,omitempty"`
+	NameJP       string   `json:"nameJP,omitempty"`
+	TextEN       []string `json:"textEN,omitempty"`
+	TextJP       []string `json:"textJP,omitempty"`
+	TraitsEN     []string `json:"traitsEN,omitempty"`
+	TraitsJP     []string `json:"traitsJP,omitempty"`
+}
+
+// enIDPrefixRE matches the E the EN site puts before the number of the cards
+// of JP sets, e.g. TSK/S82-E070 for TSK/S82-070.
+var enIDPrefixRE = regexp.MustCompile(`^E([0-9])`)
+
+// LanguageKey returns the card number of c without its locale prefixes, so
+// the EN and JP printings of a card get the same key: BD/EN-W03-004 and
+// BD/W03-004 are both BD/W03-004, and the EN TSK/S82-E070 is TSK/S82-070.
+func LanguageKey(c Card) string {
+	if c.SetID == "" || c.Release == "" || c.ID == "" {
+		return strings.ToUpper(c.CardNumber)
+	}
+	release := strings.ToUpper(c.Release)
+	id := strings.ToUpper(c.ID)
+	if strings.HasPrefix(release, "EN-") || c.Language == language.English.String() {
+		release = strings.TrimPrefix(release, "EN-")
+		id = enIDPrefixRE.ReplaceAllString(id, "$1")
+	}
+	return strings.ToUpper(c.SetID) + "/" + release + "-" + id
+}
+
+// JoinLanguages matches en and jp cards by LanguageKey. Cards without a match
+// are kept with the other side empty. The result is sorted by key.
+func JoinLanguages(en, jp []Card) []BilingualCard {
+	byKey := make(map[string]*BilingualCard)
+	get := func(c Card) *BilingualCard {
+		key := LanguageKey(c)
+		if b, ok := byKey[key]; ok {
+			return b
+		}
+		b := &BilingualCard{Key: key}
+		byKey[key] = b
+		return b
+	}
+	for _, c := range en {
+		b := get(c)
+		b.CardNumberEN, b.NameEN, b.TextEN, b.TraitsEN = c.CardNumber, c.Name, c.Text, c.Traits
+	}
+	for _, c := range jp {
+		b := get(c)
+		b.CardNumberJP, b.NameJP, b.TextJP, b.TraitsJP = c.CardNumber, c.Name, c.Text, c.Traits
+	}
+
+	cards := make([]BilingualCard, 0, len(byKey))
+	for _, b := range byKey {
+		cards = append(cards, *b)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Key < cards[j].Key })
+	return cards
+}
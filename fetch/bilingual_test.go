@@ -0,0 +1,48 @@
+package fetch
+
+import (
+	"testing"
+)
+
+func TestLanguageKey(t *testing.T) {
+	tests := map[string]Card{
+		"BD/W63-036":    {CardNumber: "BD/W63-036", SetID: "BD", Release: "W63", ID: "036"},
+		"BD/W03-004":    {CardNumber: "BD/EN-W03-004", SetID: "BD", Release: "EN-W03", ID: "004"},
+		"TSK/S82-070":   {CardNumber: "TSK/S82-E070", SetID: "TSK", Release: "S82", ID: "E070", Language: "en"},
+		"TSK/S82-E071":  {CardNumber: "TSK/S82-E071", SetID: "TSK", Release: "S82", ID: "E071", Language: "ja"},
+		"WEIRD-NUMBER1": {CardNumber: "weird-number1"},
+	}
+	for want, c := range tests {
+		if got := LanguageKey(c); got != want {
+			t.Errorf("LanguageKey(%v) = %q, want %q", c.CardNumber, got, want)
+		}
+	}
+}
+
+func TestJoinLanguages(t *testing.T) {
+	en := []Card{
+		{CardNumber: "BD/EN-W03-004", SetID: "BD", Release: "EN-W03", ID: "004", Name: "Kanon", Text: []string{"en text"}},
+		{CardNumber: "TSK/S82-E070", SetID: "TSK", Release: "S82", ID: "E070", Language: "en", Name: "Takemichi"},
+		{CardNumber: "BD/EN-W03-100", SetID: "BD", Release: "EN-W03", ID: "100", Name: "EN only"},
+	}
+	jp := []Card{
+		{CardNumber: "BD/W03-004", SetID: "BD", Release: "W03", ID: "004", Name: "花音", Text: []string{"jp text"}},
+		{CardNumber: "TSK/S82-070", SetID: "TSK", Release: "S82", ID: "070", Language: "ja", Name: "武道"},
+	}
+
+	got := JoinLanguages(en, jp)
+	if len(got) != 3 {
+		t.Fatalf("got %d cards, want 3", len(got))
+	}
+	if tsk := got[2]; tsk.Key != "TSK/S82-070" || tsk.NameEN != "Takemichi" || tsk.NameJP != "武道" {
+		t.Errorf("got %+v, want TSK/S82-070 with both languages", tsk)
+	}
+	joined := got[0]
+	if joined.Key != "BD/W03-004" || joined.NameEN != "Kanon" || joined.NameJP != "花音" ||
+		!equalSlice(joined.TextEN, []string{"en text"}) || !equalSlice(joined.TextJP, []string{"jp text"}) {
+		t.Errorf("got %+v, want BD/W03-004 with both languages", joined)
+	}
+	if enOnly := got[1]; enOnly.CardNumberEN != "BD/EN-W03-100" || enOnly.CardNumberJP != "" {
+		t.Errorf("got %+v, want the EN only card", enOnly)
+	}
+}
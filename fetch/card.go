@@ -24,9 +24,14 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
 )
 
 // Card info to export
@@ -41,6 +46,10 @@ type Card struct {
 	// SetName is the official name of the set.
 	SetName       string `json:"setName"`
 	ExpansionName string `json:"expansionName"`
+	// ExpansionID is the site's expansion number, as used by
+	// Config.ExpansionNumber. Only set when the EN detail page links to the
+	// expansion or the card was found by searching a single expansion.
+	ExpansionID int `json:"expansionId,omitempty"`
 	// Side is either "W" for Weiss, or "S" for Schwarz.
 	Side string `json:"side"`
 	// Release typically consists of the card's side, followed by a number
@@ -57,9 +66,18 @@ type Card struct {
 	// ID is chosen (eg. 2021 from BSL2021). This may be empty if there's
 	// no sensible ID to choose (eg. from TCPR-P01).
 	ReleasePackID string `json:"releasePackId"`
+	// ReleaseKind tells how to read ReleasePackID. See the ReleaseKind
+	// constants.
+	ReleaseKind string `json:"releaseKind"`
+	// ReleaseDate is the release's ISO date (eg. 2023-10-27). Only set with
+	// Config.IncludeReleaseDate.
+	ReleaseDate string `json:"releaseDate,omitempty"`
 	// ID of the card within the set+release. This is usually the last part
 	// of the card number (after the -).
 	ID string `json:"id"`
+	// CollectorNumber is the number at the start of ID (36 for 036SPMa, 70
+	// for E070SSP+), to sort a set in printed order. 0 if ID has none.
+	CollectorNumber int `json:"collectorNumber"`
 	// Language the card is printed in.
 	Language string `json:"language"`
 
@@ -68,6 +86,9 @@ type Card struct {
 
 	// Name of the card.
 	Name string `json:"name"`
+	// SearchName is Name lowercased, without diacritics and with punctuation
+	// turned into single spaces. Only set with Config.ComputeSearchName.
+	SearchName string `json:"searchName,omitempty"`
 	// Color of the card. Should be either "BLUE", "GREEN", "RED", or "YELLOW".
 	// ...Except for the two purple cards (むらさきパプリス(PY/S38-125) and むらさきぷよ(PY/S38-120)).
 	Color string `json:"color"`
@@ -86,23 +107,82 @@ type Card struct {
 	// Triggers that the card has and are activated during trigger checks.
 	Triggers []string `json:"triggers"`
 
+	// HasCXCombo is true if one of the card's abilities is a climax combo.
+	HasCXCombo bool `json:"hasCXCombo"`
+	// HasBrainstorm is true if one of the card's abilities is a brainstorm.
+	HasBrainstorm bool `json:"hasBrainstorm"`
+	// HasEncore is true if one of the card's abilities is an encore.
+	HasEncore bool `json:"hasEncore"`
+	// Keywords are the keyword abilities found in Text, see AbilityKeywords.
+	// Only set with Config.ExtractKeywords.
+	Keywords []string `json:"keywords,omitempty"`
+	// AbilityCosts are the parsed costs of the abilities in Text that have
+	// one. Only set with Config.ParseCosts.
+	AbilityCosts []AbilityCost `json:"abilityCosts,omitempty"`
+
 	FlavorText string      `json:"flavorText"`
 	ImageURL   string      `json:"imageURL"`
 	Image      image.Image `json:"-"`
 	Rarity     string      `json:"rarity"`
+	// ImageWidth and ImageHeight are the size of Image in pixels. Only set
+	// with Config.GetImages.
+	ImageWidth  int `json:"imageWidth,omitempty"`
+	ImageHeight int `json:"imageHeight,omitempty"`
+
+	// ExtraFields holds the EN detail rows the scraper doesn't know about yet,
+	// keyed by their label, so new site fields aren't lost.
+	ExtraFields map[string]string `json:"extraFields,omitempty"`
 
 	Version string `json:"version"`
+
+	// RetryCount is how many times the page the card was read from had to be
+	// fetched again: its detail page for EN, its result page for JP. Cards
+	// that needed many retries are worth checking again.
+	RetryCount int `json:"retryCount,omitempty"`
 }
 
 // CardModelVersion : Card format version
 const CardModelVersion = "1"
 
+// Values for Card.ReleaseKind.
+const (
+	// ReleaseStandard is a regular booster/trial deck release like W63 or
+	// EN-W03, where ReleasePackID is the pack number.
+	ReleaseStandard = "standard"
+	// ReleasePromo is a promo release like BSL2021 or TCPR, where
+	// ReleasePackID is a year or empty.
+	ReleasePromo = "promo"
+	// ReleaseSpecial is any other release like WE42 or WX04, where
+	// ReleasePackID is a pack number within that special series.
+	ReleaseSpecial = "special"
+)
+
 var (
 	standardCardSuffixRE = regexp.MustCompile(`(?P<setID>[a-zA-Z0-9]+)/(?P<release>[a-zA-Z0-9-]+)[-_](?P<id>[a-zA-Z0-9_]+\+?)$`)
 
+	// JP promos numbered within a regular release, like DC3/W55-PR-01. The
+	// hyphen after PR would otherwise make PR part of the release.
+	jpPromoCardRE = regexp.MustCompile(`^(?P<setID>[a-zA-Z0-9]+)/(?P<release>[a-zA-Z]+[0-9]+)-(?P<id>PR?-[0-9]+[a-zA-Z]*\+?)$`)
+
 	standardReleaseRE = regexp.MustCompile(`(?P<code>[a-zA-Z-]+)(?P<packID>[0-9]+)`)
+
+	standardReleaseKindRE = regexp.MustCompile(`^(?:EN-)?[WS][0-9]+$`)
+	promoReleaseKindRE    = regexp.MustCompile(`^PR|PR$|^[A-Z]*[0-9]{4}$`)
+
+	collectorNumberRE = regexp.MustCompile(`^[^0-9]*([0-9]+)`)
 )
 
+// collectorNumber returns the number at the start of a card ID, skipping a
+// letter prefix like the E of E070.
+func collectorNumber(id string) (int, bool) {
+	m := collectorNumberRE.FindStringSubmatch(id)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
 var suffix = []string{
 	"SP",
 	"S",
@@ -124,8 +204,134 @@ var baseRarity = []string{
 	"TD",
 	"U",
 	"AR",
+	"N",
+}
+
+// Keywords marking ability types in the card text, in both languages.
+var (
+	cxComboKeywords    = []string{"【CXCOMBO】", "【CXコンボ】"}
+	brainstormKeywords = []string{"Brainstorm", "集中"}
+	encoreKeywords     = []string{"Encore", "アンコール"}
+)
+
+type rarityInfo struct {
+	name string
+	tier int
+}
+
+// rarities describes the known rarity codes, every baseRarity and the foils.
+// Tiers go up with how hard a card is to pull, foils being above every base
+// rarity.
+var rarities = map[string]rarityInfo{
+	"TD":   {"Trial Deck", 1},
+	"PR":   {"Promo", 1},
+	"PS":   {"Promo Special", 1},
+	"N":    {"Normal", 2},
+	"C":    {"Common", 2},
+	"CC":   {"Climax Common", 2},
+	"RE":   {"Reprint", 2},
+	"U":    {"Uncommon", 3},
+	"MR":   {"Mini Rare", 3},
+	"R":    {"Rare", 4},
+	"CR":   {"Climax Rare", 4},
+	"FR":   {"Festival Rare", 4},
+	"AR":   {"Another Rare", 4},
+	"RR":   {"Double Rare", 5},
+	"RR+":  {"Double Rare+", 6},
+	"RRR":  {"Triple Rare", 7},
+	"SR":   {"Super Rare", 8},
+	"OFR":  {"Over Frame Rare", 9},
+	"SP":   {"Special", 10},
+	"SSP":  {"Super Special", 11},
+	"SSP+": {"Super Special+", 12},
+	"SEC":  {"Secret", 13},
+}
+
+// Equal reports whether c and other have the same JSON form, comparing slices
+// and maps by content. A nil slice differs from an empty one as they're
+// written as null and []. Image and RetryCount are ignored.
+func (c Card) Equal(other Card) bool {
+	return len(diffFields(c, other, true)) == 0
+}
+
+// RarityName returns the full name of the card's rarity, or "" if the rarity
+// isn't known.
+func (c Card) RarityName() string {
+	return rarities[c.Rarity].name
+}
+
+// RarityTier returns an ordinal to sort cards by rarity, higher being rarer.
+// Unknown rarities are 0.
+func (c Card) RarityTier() int {
+	return rarities[c.Rarity].tier
+}
+
+// enCardType maps the EN site's card type to "CH", "EV" or "CX". The match
+// ignores case and a trailing "Card", so "Climax Card" is a climax. Unknown
+// types return "".
+func enCardType(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimSpace(strings.TrimSuffix(s, "card"))
+	switch s {
+	case "event":
+		return "EV"
+	case "character":
+		return "CH"
+	case "climax":
+		return "CX"
+	}
+	return ""
+}
+
+// DetailURL returns the page of the card on the official site of its
+// Language, or "" if the language isn't supported.
+func (c Card) DetailURL() string {
+	siteCfg, ok := siteConfigs[SiteLanguage(language.Make(c.Language))]
+	if !ok || c.CardNumber == "" {
+		return ""
+	}
+	return cardPermalink(siteCfg, c.CardNumber)
+}
+
+// TriggerCounts returns how many times each trigger appears on the card,
+// e.g. {"SOUL": 2} for a double soul climax.
+func (c Card) TriggerCounts() map[string]int {
+	counts := make(map[string]int, len(c.Triggers))
+	for _, trigger := range c.Triggers {
+		counts[trigger]++
+	}
+	return counts
 }
 
+// grantedSoulREs match the soul boost of a climax's text on the EN and JP
+// sites.
+var grantedSoulREs = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\+(\d+) soul`),
+	regexp.MustCompile(`ソウルを[+＋]([0-9０-９]+)`),
+}
+
+// GrantedSoul returns the soul a climax gives to characters, e.g. 2 for "All of
+// your characters get +2 soul.", which is different from the card's own Soul.
+// It's false for other card types or if the text has no soul boost.
+func (c Card) GrantedSoul() (int, bool) {
+	if c.Type != "CX" {
+		return 0, false
+	}
+	for _, line := range c.Text {
+		for _, re := range grantedSoulREs {
+			if m := re.FindStringSubmatch(line); m != nil {
+				if n, err := strconv.Atoi(width.Narrow.String(m[1])); err == nil {
+					return n, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// triggersMap maps the trigger icon file names (without extension) to the
+// trigger names. Both sites currently use the same names, see
+// siteConfig.triggers.
 var triggersMap = map[string]string{
 	"soul":     "SOUL",
 	"salvage":  "COMEBACK",
@@ -139,11 +345,58 @@ var triggersMap = map[string]string{
 	"choice":   "CHOICE",
 }
 
-func filterDash(st string) string {
-	if strings.Contains(st, "-") {
+// labelTriggers returns triggers with the names replaced by their label, if
+// they have one.
+func labelTriggers(triggers, labels map[string]string) map[string]string {
+	labeled := make(map[string]string, len(triggers))
+	for icon, name := range triggers {
+		if label, ok := labels[name]; ok {
+			name = label
+		}
+		labeled[icon] = name
+	}
+	return labeled
+}
+
+// applyTriggerLabels sets up c for cfg.TriggerLabels and cfg.LabelTriggers.
+func (c *siteConfig) applyTriggerLabels(cfg Config) {
+	if len(cfg.TriggerLabels) == 0 {
+		return
+	}
+	c.textTriggers = labelTriggers(c.triggers, cfg.TriggerLabels)
+	if cfg.LabelTriggers {
+		c.triggers = c.textTriggers
+	}
+}
+
+// abilityTriggers returns the trigger names to write in the ability text.
+func (c siteConfig) abilityTriggers() map[string]string {
+	if c.textTriggers != nil {
+		return c.textTriggers
+	}
+	return c.triggers
+}
+
+// normalizeNumber cleans up a numeric field. Full-width digits become ASCII
+// and anything that isn't a digit is dropped, except for a leading minus.
+// A field without digits (usually "-") is empty.
+func normalizeNumber(st string) string {
+	st = strings.TrimSpace(width.Narrow.String(st))
+	var res strings.Builder
+	hasDigit := false
+	for i, r := range st {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+			res.WriteRune(r)
+		case r == '-' && i == 0:
+			res.WriteRune(r)
+		}
+	}
+	if !hasDigit {
 		return ""
 	}
-	return st
+	return res.String()
 }
 
 // extractData extract data to card
@@ -159,12 +412,34 @@ func extractData(config siteConfig, mainHTML *goquery.Selection) Card {
 	}
 }
 
+// ParseCard extracts a card from saved HTML. The HTML is the card detail
+// block for EN (.p-cards__detail-wrapper) or the search result row for JP.
+func ParseCard(rawHTML string, lang SiteLanguage) (Card, error) {
+	config, ok := siteConfigs[lang]
+	if !ok {
+		return Card{}, fmt.Errorf("unsupported language: %v", lang)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return Card{}, fmt.Errorf("couldn't parse HTML: %v", err)
+	}
+	card := extractData(config, doc.Selection)
+	if card.CardNumber == "" {
+		return card, fmt.Errorf("no card found in HTML")
+	}
+	return card, nil
+}
+
 func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 	txtArea := mainHTML.Find(".p-cards__detail-textarea").Last()
 	cardNumber := txtArea.Find(".number").First().Last().Text()
 	defer func() {
 		if err := recover(); err != nil {
 			slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Panic during card extraction=%v", err))
+			config.errors.add(ScrapeError{CardNumber: cardNumber, Stage: StageExtract, Message: fmt.Sprint(err)})
+			if config.panicOnExtractError {
+				panic(err)
+			}
 		}
 	}()
 
@@ -173,23 +448,22 @@ func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 
 	setID, release, releasePackID, cardID := parseCardNumber(cardNumber)
 
-	cardName := mainHTML.Find(".ttl").Last().Text()
+	cardName := strings.TrimSpace(unescapeText(mainHTML.Find(".ttl").Last().Text()))
 	imageCardURL, _ := mainHTML.Find("div.image img").Attr("src")
 
 	info := make(map[string]string)
+	var extra map[string]string
+	var expansionID int
 	mainHTML.Find("dl").Each(func(i int, s *goquery.Selection) {
 		dt := strings.TrimSpace(s.Find("dt").First().Text())
 		dd := s.Find("dd").First()
 		ddText := strings.TrimSpace(dd.Text())
 		switch dt {
 		case "Card Type":
-			switch ddText {
-			case "Event":
-				info["type"] = "EV"
-			case "Character":
-				info["type"] = "CH"
-			case "Climax":
-				info["type"] = "CX"
+			if t := enCardType(ddText); t != "" {
+				info["type"] = t
+			} else {
+				slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Unknown card type: %q", ddText))
 			}
 		case "Color":
 			if u, ok := dd.Find("img").First().Attr("src"); ok {
@@ -205,7 +479,10 @@ func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 		case "Cost":
 			info["cost"] = ddText
 		case "Expansion":
-			info["expansion"] = ddText
+			info["expansion"] = unescapeText(ddText)
+			if m := expansionHrefRE.FindStringSubmatch(dd.Find("a").AttrOr("href", "")); m != nil {
+				expansionID, _ = strconv.Atoi(m[1])
+			}
 		case "Level":
 			info["level"] = ddText
 		case "Power":
@@ -230,21 +507,27 @@ func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 					res.WriteString(" ")
 				}
 				_, trigger := path.Split(ss.AttrOr("src", "yay"))
-				res.WriteString(triggersMap[strings.Split(trigger, ".")[0]])
+				res.WriteString(config.triggers[strings.Split(trigger, ".")[0]])
 			})
 			info["trigger"] = strings.ToUpper(strings.TrimSpace(res.String()))
 		default:
-			slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Unknown detail: %v", dt))
+			slog.With("cardnumber", cardNumber).Debug(fmt.Sprintf("Unknown detail: %v", dt), "value", ddText)
+			if dt != "" {
+				if extra == nil {
+					extra = make(map[string]string)
+				}
+				extra[dt] = unescapeText(ddText)
+			}
 		}
 	})
 
 	// Flavor text
-	flvr := strings.TrimSpace(txtArea.Find(".p-cards__detail-serif").Text())
+	flvr := multilineText(txtArea.Find(".p-cards__detail-serif"))
 	if flvr != "" && flvr != "-" && flvr != "―" {
-		info["flavourText"] = flvr
+		info["flavourText"] = unescapeText(flvr)
 	}
 
-	ability, err := extractAbilities(mainHTML.Find(".p-cards__detail p").Last())
+	ability, err := extractAbilities(mainHTML.Find(".p-cards__detail p").Last(), config.abilityTriggers())
 	if err != nil {
 		slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Failed to get ability node: %v", err))
 	}
@@ -255,28 +538,26 @@ func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 		// TODO: Figure out how to get EN set name. It's no longer on the card details page
 		// SetName:     setName,
 		ExpansionName: info["expansion"],
+		ExpansionID:   expansionID,
 		Side:          info["side"],
 		Release:       release,
 		ReleasePackID: releasePackID,
+		ReleaseKind:   releaseKind(release),
 		ID:            cardID,
 		Language:      language.English.String(),
 		Type:          info["type"],
 		Name:          cardName,
-		Level:         filterDash(info["level"]),
-		Cost:          filterDash(info["cost"]),
+		Level:         normalizeNumber(info["level"]),
+		Cost:          normalizeNumber(info["cost"]),
 		FlavorText:    info["flavourText"],
 		Color:         info["color"],
-		Power:         filterDash(info["power"]),
+		Power:         normalizeNumber(info["power"]),
 		Rarity:        info["rarity"],
 		Text:          ability,
+		ExtraFields:   extra,
 		Version:       CardModelVersion,
 	}
-	if fullURL, err := joinPath(config.baseURL, imageCardURL); err == nil {
-		card.ImageURL = fullURL.String()
-	} else {
-		slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Couldn't form full image URL: %v", err))
-		card.ImageURL = imageCardURL
-	}
+	card.ImageURL = cardImageURL(config.baseURL, imageCardURL, cardNumber)
 	if info["specialAttribute"] != "" {
 		card.Traits = strings.Split(info["specialAttribute"], "・")
 	}
@@ -284,16 +565,33 @@ func extractDataEn(config siteConfig, mainHTML *goquery.Selection) Card {
 		card.Triggers = strings.Split(info["trigger"], " ")
 	}
 	if card.Type == "CH" {
-		card.Soul = info["soul"]
+		card.Soul = normalizeNumber(info["soul"])
 	}
+	card.CollectorNumber, _ = collectorNumber(card.ID)
+	normalizeSlices(&card)
+	setAbilityFlags(&card)
 	return card
 }
 
+// jpAbilityNode returns the span holding a JP card's abilities: the last span
+// that isn't part of the name or of a .unit detail. The same span is found
+// whether the card comes as a <tr> or as bare <th>/<td>, and a card without
+// abilities gets an empty selection instead of its flavor text.
+func jpAbilityNode(mainHTML *goquery.Selection) *goquery.Selection {
+	return mainHTML.Find("span").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return !s.HasClass("unit") && s.ParentsFiltered(".unit, h4").Length() == 0
+	}).Last()
+}
+
 func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 	rawCardNumber := mainHTML.Find("h4 span").Last().Text()
 	defer func() {
 		if err := recover(); err != nil {
 			slog.With("cardnumber", rawCardNumber).Error(fmt.Sprintf("Panic during card extraction=%v", err))
+			config.errors.add(ScrapeError{CardNumber: rawCardNumber, Stage: StageExtract, Message: fmt.Sprint(err)})
+			if config.panicOnExtractError {
+				panic(err)
+			}
 		}
 	}()
 
@@ -302,10 +600,10 @@ func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 
 	setID, release, releasePackID, cardID := parseCardNumber(cardNumber)
 
-	setName := strings.TrimSpace(strings.Split(mainHTML.Find("h4").Text(), ") -")[1])
+	setName := unescapeText(strings.TrimSpace(strings.Split(mainHTML.Find("h4").Text(), ") -")[1]))
 	imageCardURL, _ := mainHTML.Find("a img").Attr("src")
 
-	ability, err := extractAbilities(mainHTML.Find("span").Last())
+	ability, err := extractAbilities(jpAbilityNode(mainHTML), config.abilityTriggers())
 	if err != nil {
 		slog.With("cardnumber", rawCardNumber).Error(fmt.Sprintf("Failed to get ability node: %v", err))
 	}
@@ -337,7 +635,7 @@ func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 			// Flavor text
 		case strings.HasPrefix(txt, "フレーバー："):
 			flvr := strings.TrimSpace(strings.TrimPrefix(txt, "フレーバー："))
-			infos["flavourText"] = flvr
+			infos["flavourText"] = unescapeText(flvr)
 			// Level
 		case strings.HasPrefix(txt, "レベル："):
 			lvl := strings.TrimSpace(strings.TrimPrefix(txt, "レベル："))
@@ -365,7 +663,7 @@ func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 					res.WriteString(" ")
 				}
 				_, trigger := path.Split(ss.AttrOr("src", "yay"))
-				res.WriteString(triggersMap[strings.Split(trigger, ".")[0]])
+				res.WriteString(config.triggers[strings.Split(trigger, ".")[0]])
 			})
 			infos["trigger"] = strings.ToUpper(strings.TrimSpace(res.String()))
 			// Trait
@@ -391,25 +689,21 @@ func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 		Side:          infos["side"],
 		Release:       release,
 		ReleasePackID: releasePackID,
+		ReleaseKind:   releaseKind(release),
 		ID:            cardID,
 		Language:      language.Japanese.String(),
 		Type:          infos["type"],
-		Name:          strings.TrimSpace(mainHTML.Find("h4 span").First().Text()),
-		Level:         filterDash(infos["level"]),
+		Name:          strings.TrimSpace(unescapeText(mainHTML.Find("h4 span").First().Text())),
+		Level:         normalizeNumber(infos["level"]),
 		FlavorText:    infos["flavourText"],
 		Color:         infos["color"],
-		Power:         filterDash(infos["power"]),
-		Cost:          filterDash(infos["cost"]),
+		Power:         normalizeNumber(infos["power"]),
+		Cost:          normalizeNumber(infos["cost"]),
 		Rarity:        infos["rarity"],
 		Text:          ability,
 		Version:       CardModelVersion,
 	}
-	if fullURL, err := joinPath(config.baseURL, imageCardURL); err == nil {
-		card.ImageURL = fullURL.String()
-	} else {
-		slog.With("cardnumber", rawCardNumber).Error(fmt.Sprintf("Couldn't form full image URL: %v", err))
-		card.ImageURL = imageCardURL
-	}
+	card.ImageURL = cardImageURL(config.baseURL, imageCardURL, rawCardNumber)
 	if infos["specialAttribute"] != "" {
 		card.Traits = strings.Split(infos["specialAttribute"], "・")
 	}
@@ -417,19 +711,160 @@ func extractDataJp(config siteConfig, mainHTML *goquery.Selection) Card {
 		card.Triggers = strings.Split(infos["trigger"], " ")
 	}
 	if card.Type == "CH" {
-		card.Soul = infos["soul"]
+		card.Soul = normalizeNumber(infos["soul"])
 	}
+	card.CollectorNumber, _ = collectorNumber(card.ID)
+	normalizeSlices(&card)
+	setAbilityFlags(&card)
 	return card
 }
 
-func extractAbilities(abilityNode *goquery.Selection) ([]string, error) {
+// setAbilityFlags scans the card text for ability keywords.
+func setAbilityFlags(card *Card) {
+	card.HasCXCombo = textContainsAny(card.Text, cxComboKeywords)
+	card.HasBrainstorm = textContainsAny(card.Text, brainstormKeywords)
+	card.HasEncore = textContainsAny(card.Text, encoreKeywords)
+}
+
+// AbilityKeywords are the keyword abilities cardKeywords looks for, in the
+// order they're reported.
+var AbilityKeywords = []string{"Alarm", "Encore", "Bond", "Change", "Brainstorm", "Backup", "Assist", "Experience", "Memory", "CXCOMBO"}
+
+// abilityKeywordREs match each of AbilityKeywords in EN or JP card text. The
+// EN words need word boundaries so e.g. "Exchange" isn't a Change.
+var abilityKeywordREs = map[string]*regexp.Regexp{
+	"Alarm":      regexp.MustCompile(`\bAlarm\b|アラーム`),
+	"Encore":     regexp.MustCompile(`\bEncore\b|アンコール`),
+	"Bond":       regexp.MustCompile(`\bBond\b|絆`),
+	"Change":     regexp.MustCompile(`\bChange\b|チェンジ`),
+	"Brainstorm": regexp.MustCompile(`\bBrainstorm\b|集中`),
+	"Backup":     regexp.MustCompile(`\bBackup\b|助太刀`),
+	"Assist":     regexp.MustCompile(`\bAssist\b|応援`),
+	"Experience": regexp.MustCompile(`\bExperience\b|経験`),
+	"Memory":     regexp.MustCompile(`\bMemory\b|記憶`),
+	"CXCOMBO":    regexp.MustCompile(`【CXCOMBO】|【CXコンボ】`),
+}
+
+// cardKeywords returns the AbilityKeywords found in text.
+func cardKeywords(text []string) []string {
+	keywords := []string{}
+	for _, k := range AbilityKeywords {
+		for _, line := range text {
+			if abilityKeywordREs[k].MatchString(line) {
+				keywords = append(keywords, k)
+				break
+			}
+		}
+	}
+	return keywords
+}
+
+func textContainsAny(text []string, keywords []string) bool {
+	for _, line := range text {
+		for _, k := range keywords {
+			if strings.Contains(line, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unescapeText decodes HTML entities left over in text that goquery already
+// decoded once, e.g. double-encoded "&amp;amp;" in the site's markup.
+func unescapeText(s string) string {
+	return html.UnescapeString(s)
+}
+
+// searchName normalizes a card name for case and diacritic insensitive search.
+func searchName(name string) string {
+	// Keep the kana voicing marks, they aren't diacritics in Japanese.
+	isDiacritic := func(r rune) bool {
+		return unicode.Is(unicode.Mn, r) && r != '\u3099' && r != '\u309A'
+	}
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.Predicate(isDiacritic)), norm.NFC)
+	s, _, err := transform.String(t, name)
+	if err != nil {
+		s = name
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// MergeCard merges a freshly scraped card into one stored earlier.
+// The fresh card wins for every field, except for ImageURL and the image
+// size: when the fresh card has none, for example because it was fetched
+// without images, the stored values are kept. The size is only kept if the
+// image is the same.
+func MergeCard(existing, fresh Card) Card {
+	merged := fresh
+	if merged.ImageURL == "" {
+		merged.ImageURL = existing.ImageURL
+	}
+	if merged.ImageWidth == 0 && merged.ImageHeight == 0 && merged.ImageURL == existing.ImageURL {
+		merged.ImageWidth, merged.ImageHeight = existing.ImageWidth, existing.ImageHeight
+	}
+	return merged
+}
+
+// cardImageURL resolves a card image's src against baseURL. Cards without an
+// image (e.g. some text-only promos) get "" rather than the base URL.
+func cardImageURL(baseURL, src, cardNumber string) string {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		slog.With("cardnumber", cardNumber).Debug("Card has no image")
+		return ""
+	}
+	fullURL, err := joinPath(baseURL, src)
+	if err != nil {
+		slog.With("cardnumber", cardNumber).Error(fmt.Sprintf("Couldn't form full image URL: %v", err))
+		return src
+	}
+	return fullURL.String()
+}
+
+// normalizeSlices replaces nil slices with empty ones so they're marshaled as
+// [] instead of null.
+func normalizeSlices(card *Card) {
+	if card.Text == nil {
+		card.Text = []string{}
+	}
+	if card.Traits == nil {
+		card.Traits = []string{}
+	}
+	if card.Triggers == nil {
+		card.Triggers = []string{}
+	}
+}
+
+// multilineText returns the text of sel with each <br> as a line break. Lines
+// are trimmed and empty ones dropped.
+func multilineText(sel *goquery.Selection) string {
+	sel.Find("br").ReplaceWithHtml("\n")
+	var lines []string
+	for _, line := range strings.Split(sel.Text(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// extractAbilities returns the ability lines of abilityNode, with the trigger
+// icons replaced by their name from triggers.
+func extractAbilities(abilityNode *goquery.Selection, triggers map[string]string) ([]string, error) {
 	var ability []string
 	abilityNode.Find("img").Each(func(i int, s *goquery.Selection) {
 		url, has := s.Attr("src")
 		if has {
 			_, _imgPlaceHolder := path.Split(url)
 			_imgPlaceHolder = strings.Split(_imgPlaceHolder, ".")[0]
-			t := fmt.Sprintf("[%v]", triggersMap[_imgPlaceHolder])
+			t := fmt.Sprintf("[%v]", triggers[_imgPlaceHolder])
 			s.ReplaceWithHtml(t)
 		}
 	})
@@ -448,6 +883,9 @@ func extractAbilities(abilityNode *goquery.Selection) ([]string, error) {
 }
 
 func sanitizeCardNumber(cn string) string {
+	// Some pages pad the number with whitespace and newlines.
+	cn = collapseSpaces(cn)
+
 	// The website sometimes shows "%2B" instead of + for some cards (eg. SSP+ rarity).
 	cn = strings.ReplaceAll(cn, "%2B", "+")
 
@@ -481,10 +919,37 @@ func sanitizeCardNumber(cn string) string {
 		}
 		cn = strings.Replace(cn, "+", " ", repCnt)
 	}
-	return cn
+	return collapseSpaces(cn)
+}
+
+// collapseSpaces trims s and turns every run of whitespace into one space.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// releaseKind classifies a release code, see the ReleaseKind constants.
+func releaseKind(release string) string {
+	switch {
+	case release == "":
+		return ""
+	case standardReleaseKindRE.MatchString(release):
+		return ReleaseStandard
+	case promoReleaseKindRE.MatchString(release):
+		return ReleasePromo
+	default:
+		return ReleaseSpecial
+	}
 }
 
 func parseCardNumber(cn string) (setID, release, releasePackID, id string) {
+	if matches := jpPromoCardRE.FindStringSubmatch(cn); matches != nil {
+		setID, release, id = matches[1], matches[2], matches[3]
+		if relMatches := standardReleaseRE.FindStringSubmatch(release); relMatches != nil {
+			releasePackID = relMatches[2]
+		}
+		return
+	}
+
 	if matches := standardCardSuffixRE.FindStringSubmatch(cn); matches != nil {
 		setID = matches[1]
 		release = matches[2]
@@ -528,8 +993,10 @@ func IsbaseRarity(card Card) bool {
 }
 
 func isTrullyNotFoil(card Card) bool {
+	// A trailing + (e.g. E070SSP+) isn't part of the rarity suffix.
+	id := strings.TrimRight(strings.TrimSpace(card.ID), "+ ")
 	for _, _suffix := range suffix {
-		if strings.HasSuffix(card.ID, _suffix) {
+		if strings.HasSuffix(id, _suffix) {
 			return false
 		}
 	}
@@ -1,12 +1,15 @@
 package fetch
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"strings"
 	"testing"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/language"
 )
 
 func equalSlice(sliceA []string, sliceB []string) bool {
@@ -33,68 +36,11 @@ func assertCardEqualsWithTitle(t *testing.T, title string, got, want Card) {
 	if title != "" {
 		prefix = fmt.Sprintf("[%s]: ", title)
 	}
-	if got.SetID != want.SetID {
-		t.Errorf("%sIncorrect Set: got %q, want %q", prefix, got.SetID, want.SetID)
+	if got.Equal(want) {
+		return
 	}
-	if got.SetName != want.SetName {
-		t.Errorf("%sIncorrect SetName: got %q, want %q", prefix, got.SetName, want.SetName)
-	}
-	if got.Side != want.Side {
-		t.Errorf("%sIncorrect Side: got %q, want %q", prefix, got.Side, want.Side)
-	}
-	if got.Release != want.Release {
-		t.Errorf("%sIncorrect Release: got %q, want %q", prefix, got.Release, want.Release)
-	}
-	if got.ID != want.ID {
-		t.Errorf("%sIncorrect ID: got %q, want %q", prefix, got.ID, want.ID)
-	}
-	if got.Name != want.Name {
-		t.Errorf("%sIncorrect Name: got %q, want %q", prefix, got.Name, want.Name)
-	}
-	if got.Language != want.Language {
-		t.Errorf("%sIncorrect Language: got %q, want %q", prefix, got.Language, want.Language)
-	}
-	if got.Type != want.Type {
-		t.Errorf("%sIncorrect CardType: got %q, want %q", prefix, got.Type, want.Type)
-	}
-	if got.Color != want.Color {
-		t.Errorf("%sIncorrect Colour: got %q, want %q", prefix, got.Color, want.Color)
-	}
-	if got.Level != want.Level {
-		t.Errorf("%sIncorrect Level: got %q, want %q", prefix, got.Level, want.Level)
-	}
-	if got.Cost != want.Cost {
-		t.Errorf("%sIncorrect Cost: got %q, want %q", prefix, got.Cost, want.Cost)
-	}
-	if got.Power != want.Power {
-		t.Errorf("%sIncorrect Power: got %q, want %q", prefix, got.Power, want.Power)
-	}
-	if got.Soul != want.Soul {
-		t.Errorf("%sIncorrect Soul: got %q, want %q", prefix, got.Soul, want.Soul)
-	}
-	if got.Rarity != want.Rarity {
-		t.Errorf("%sIncorrect Rarity: got %q, want %q", prefix, got.Rarity, want.Rarity)
-	}
-	if got.FlavorText != want.FlavorText {
-		t.Errorf("%sIncorrect FlavourText: got %q, want %q", prefix, got.FlavorText, want.FlavorText)
-	}
-	if !equalSlice(got.Triggers, want.Triggers) {
-		t.Errorf("%sIncorrect Trigger: got %v, want %v", prefix, got.Triggers, want.Triggers)
-	}
-	if !equalSlice(got.Text, want.Text) {
-		t.Errorf("%sIncorrect Ability: got\n %v,\nwant\n %v", prefix, got.Text, want.Text)
-	}
-	if !equalSlice(got.Traits, want.Traits) {
-		t.Errorf("%sIncorrect SpecialAttrib: got %v, want %v", prefix, got.Traits, want.Traits)
-	}
-	if got.Version != want.Version {
-		t.Errorf("%sIncorrect Version: got %q, want %q", prefix, got.Version, want.Version)
-	}
-	if got.ImageURL != want.ImageURL {
-		t.Errorf("%sIncorrect ImageURL: got %q, want %q", prefix, got.ImageURL, want.ImageURL)
-	}
-	if got.CardNumber != want.CardNumber {
-		t.Errorf("%sIncorrect Cardcode: got %q, want %q", prefix, got.CardNumber, want.CardNumber)
+	for _, f := range diffFields(want, got, true) {
+		t.Errorf("%sIncorrect %s: got %#v, want %#v", prefix, f.Field, f.New, f.Old)
 	}
 }
 
@@ -245,6 +191,73 @@ func TestExtractDataEvent_jp(t *testing.T) {
 	}
 }
 
+func TestExtractDataSiteTriggers_jp(t *testing.T) {
+	chara := `
+<tr>
+	<th><a href="/cardlist/?cardno=BD/W63-025&amp;l"><img src="/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png" alt="キラキラのお日様"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-025&amp;l"><span class="highlight_target">
+	キラキラのお日様</span>(<span class="highlight_target">BD/W63-025</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br></h4>
+	<span class="unit">種類：クライマックス</span>
+	<span class="unit">トリガー：<img src="/wordpress/wp-content/images/cardlist/_partimages/jp_soul.gif"></span>
+	<br>
+	<span class="highlight_target">（<img src="/wordpress/wp-content/images/cardlist/_partimages/jp_soul.gif">：このカードがトリガーした時）</span>
+	</td>
+</tr>
+	`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	siteCfg := siteConfigs[Japanese]
+	siteCfg.triggers = maps.Clone(triggersMap)
+	siteCfg.triggers["jp_soul"] = "SOUL"
+	card := extractData(siteCfg, doc.Selection)
+
+	if !equalSlice(card.Triggers, []string{"SOUL"}) {
+		t.Errorf("got triggers %v: expected [SOUL]", card.Triggers)
+	}
+	if want := []string{"（[SOUL]：このカードがトリガーした時）"}; !equalSlice(card.Text, want) {
+		t.Errorf("got text %q: expected %q", card.Text, want)
+	}
+	if _, ok := siteConfigs[English].triggers["jp_soul"]; ok {
+		t.Error("the JP trigger file name leaked into the EN map")
+	}
+}
+
+func TestTriggerLabels(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<p>(<img src="/wp/wp-content/images/partimages/soul.gif">: When this card triggers)<br>(<img src="/wp/wp-content/images/partimages/gate.gif">: When this card triggers)</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{TriggerLabels: map[string]string{"SOUL": "S"}}
+
+	siteCfg := siteConfigs[English]
+	siteCfg.applyTriggerLabels(cfg)
+	ability, err := extractAbilities(doc.Find("p"), siteCfg.abilityTriggers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"([S]: When this card triggers)", "([GATE]: When this card triggers)"}; !equalSlice(ability, want) {
+		t.Errorf("got %q: expected %q", ability, want)
+	}
+	if siteCfg.triggers["soul"] != "SOUL" {
+		t.Errorf("got trigger %q: expected SOUL without LabelTriggers", siteCfg.triggers["soul"])
+	}
+	if triggersMap["soul"] != "SOUL" {
+		t.Error("the labels leaked into triggersMap")
+	}
+
+	cfg.LabelTriggers = true
+	siteCfg = siteConfigs[English]
+	siteCfg.applyTriggerLabels(cfg)
+	if siteCfg.triggers["soul"] != "S" {
+		t.Errorf("got trigger %q: expected S with LabelTriggers", siteCfg.triggers["soul"])
+	}
+}
+
 func TestExtractDataCX_jp(t *testing.T) {
 	chara := `
 <tr>
@@ -278,26 +291,29 @@ func TestExtractDataCX_jp(t *testing.T) {
 	card := extractData(siteConfigs[Japanese], doc.Clone())
 
 	expectedCard := Card{
-		Name:          "キラキラのお日様",
-		SetID:         "BD",
-		SetName:       "「バンドリ！ ガールズバンドパーティ！」Vol.2",
-		Side:          "W",
-		CardNumber:    "BD/W63-025",
-		Release:       "W63",
-		ReleasePackID: "63",
-		ID:            "025",
-		Color:         "YELLOW",
-		Language:      "ja",
-		Type:          "CX",
-		Soul:          "",
-		Level:         "",
-		Cost:          "",
-		FlavorText:    "楽しい気持ちは誰かといると生まれるものってこと！",
-		Power:         "",
-		Rarity:        "CR",
-		ImageURL:      "https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png",
-		Version:       CardModelVersion,
-		Triggers:      []string{"SOUL", "RETURN"},
+		Name:            "キラキラのお日様",
+		SetID:           "BD",
+		SetName:         "「バンドリ！ ガールズバンドパーティ！」Vol.2",
+		Side:            "W",
+		CardNumber:      "BD/W63-025",
+		Release:         "W63",
+		ReleasePackID:   "63",
+		ReleaseKind:     ReleaseStandard,
+		ID:              "025",
+		CollectorNumber: 25,
+		Color:           "YELLOW",
+		Language:        "ja",
+		Type:            "CX",
+		Soul:            "",
+		Level:           "",
+		Cost:            "",
+		FlavorText:      "楽しい気持ちは誰かといると生まれるものってこと！",
+		Power:           "",
+		Rarity:          "CR",
+		ImageURL:        "https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png",
+		Version:         CardModelVersion,
+		Triggers:        []string{"SOUL", "RETURN"},
+		Traits:          []string{},
 		Text: []string{
 			"【永】 あなたのキャラすべてに、パワーを＋1000し、ソウルを＋1。",
 			"（[RETURN]：このカードがトリガーした時、あなたは相手のキャラを1枚選び、手札に戻してよい）",
@@ -384,27 +400,30 @@ func TestExtractData_en(t *testing.T) {
 
 	card := extractData(siteConfigs[English], doc.Clone())
 	expectedCard := Card{
-		Name:          "EGOISTIC, Sakura",
-		ExpansionName: "PR Card 【Schwarz Side】",
-		CardNumber:    "FS/BCS2019-03",
-		SetID:         "FS",
-		Side:          "S",
-		Release:       "BCS2019",
-		ReleasePackID: "2019",
-		ID:            "03",
-		Level:         "0",
-		Color:         "GREEN",
-		Power:         "2000",
-		Soul:          "1",
-		Cost:          "0",
-		Language:      "en",
-		Type:          "CH",
-		Rarity:        "PR",
-		FlavorText:    "I wish someone like this didn't exist.",
-		Traits:        []string{"Master", "Love"},
-		Text:          []string{"【AUTO】 When this card is placed on the stage from your hand, choose 1 of your 《Master》 or 《Servant》 characters, and that character gets +1500 power until end of turn."},
-		ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/f/fs_s64/FS_BCS_2019_03.png",
-		Version:       CardModelVersion,
+		Name:            "EGOISTIC, Sakura",
+		ExpansionName:   "PR Card 【Schwarz Side】",
+		CardNumber:      "FS/BCS2019-03",
+		SetID:           "FS",
+		Side:            "S",
+		Release:         "BCS2019",
+		ReleasePackID:   "2019",
+		ReleaseKind:     ReleasePromo,
+		ID:              "03",
+		CollectorNumber: 3,
+		Level:           "0",
+		Color:           "GREEN",
+		Power:           "2000",
+		Soul:            "1",
+		Cost:            "0",
+		Language:        "en",
+		Type:            "CH",
+		Rarity:          "PR",
+		FlavorText:      "I wish someone like this didn't exist.",
+		Triggers:        []string{},
+		Traits:          []string{"Master", "Love"},
+		Text:            []string{"【AUTO】 When this card is placed on the stage from your hand, choose 1 of your 《Master》 or 《Servant》 characters, and that character gets +1500 power until end of turn."},
+		ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/f/fs_s64/FS_BCS_2019_03.png",
+		Version:         CardModelVersion,
 	}
 	assertCardEquals(t, card, expectedCard)
 }
@@ -486,26 +505,28 @@ func TestExtractData_en_multiIconAbility(t *testing.T) {
 	}
 
 	expectedCard := Card{
-		CardNumber:    "ATLA/WX04-007S",
-		SetID:         "ATLA",
-		ExpansionName: "Avatar: The Last Airbender",
-		Side:          "W",
-		Release:       "WX04",
-		ReleasePackID: "WX",
-		ID:            "007S",
-		Language:      "en",
-		Type:          "CH",
-		Name:          "Aang: Learning Avatar State",
-		Color:         "YELLOW",
-		Soul:          "0",
-		Level:         "2",
-		Cost:          "1",
-		FlavorText:    "",
-		Power:         "1000",
-		Rarity:        "SR",
-		ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/ATLA/BP/ATLA_WX04_007S.png",
-		Triggers:      []string{"SOUL"},
-		Traits:        []string{"World of Avatar", "Air Nomads"},
+		CardNumber:      "ATLA/WX04-007S",
+		SetID:           "ATLA",
+		ExpansionName:   "Avatar: The Last Airbender",
+		Side:            "W",
+		Release:         "WX04",
+		ReleasePackID:   "04",
+		ReleaseKind:     ReleaseSpecial,
+		ID:              "007S",
+		CollectorNumber: 7,
+		Language:        "en",
+		Type:            "CH",
+		Name:            "Aang: Learning Avatar State",
+		Color:           "YELLOW",
+		Soul:            "0",
+		Level:           "2",
+		Cost:            "1",
+		FlavorText:      "",
+		Power:           "1000",
+		Rarity:          "SR",
+		ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/ATLA/BP/ATLA_WX04_007S.png",
+		Triggers:        []string{"SOUL"},
+		Traits:          []string{"World of Avatar", "Air Nomads"},
 		Text: []string{
 			"【CONT】 If your climax area has a climax with [CHOICE] in its trigger icon, this card in all of your zones get [CHOICE] in the trigger icon. If there is a climax with [TREASURE] in its trigger icon, this card in all of your zones get [TREASURE] in the trigger icon. If there is a climax with [STANDBY] in its trigger icon, this card in all of your zones get [STANDBY] in the trigger icon. If there is a climax with [GATE] in its trigger icon, this card in all of your zones get [GATE] in the trigger icon.",
 			"【AUTO】 【CLOCK】 Alarm If this card is the top card of your clock, and you have 4 or more 《World of Avatar》 characters, at the beginning of your climax phase, you may put the top card of your deck into your stock.",
@@ -628,6 +649,16 @@ func TestExtractDataEvent_en(t *testing.T) {
 	if card.Power != "" {
 		t.Errorf("got %v: expected ''", card.Power)
 	}
+
+	res, err := json.Marshal(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"traits":[]`, `"triggers":[]`} {
+		if !strings.Contains(string(res), field) {
+			t.Errorf("expected %s in %s", field, res)
+		}
+	}
 }
 
 func TestExtractDataCX_en(t *testing.T) {
@@ -824,31 +855,34 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
       </div>`,
 			English,
 			Card{
-				CardNumber:    "BD/EN-W03-004",
-				SetID:         "BD",
-				ExpansionName: "BanG Dream! Girls Band Party! MULTI LIVE",
-				Side:          "W",
-				Release:       "EN-W03",
-				ReleasePackID: "03",
-				ID:            "004",
-				Language:      "en",
-				Type:          "CH",
-				Name:          `"A Nice Change" Kanon Matsubara`,
-				Color:         "YELLOW",
-				Soul:          "1",
-				Level:         "0",
-				Cost:          "0",
-				FlavorText:    "All it takes is something small for people to change the way we think and act... That's all it took for us.",
-				Power:         "1000",
-				Rarity:        "R",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/b/bd_en_w03/BD_EN_W03_004.png",
-				Triggers:      []string{},
-				Traits:        []string{"Music", "Hello, Happy World!"},
+				CardNumber:      "BD/EN-W03-004",
+				SetID:           "BD",
+				ExpansionName:   "BanG Dream! Girls Band Party! MULTI LIVE",
+				Side:            "W",
+				Release:         "EN-W03",
+				ReleasePackID:   "03",
+				ReleaseKind:     ReleaseStandard,
+				ID:              "004",
+				CollectorNumber: 4,
+				Language:        "en",
+				Type:            "CH",
+				Name:            `"A Nice Change" Kanon Matsubara`,
+				Color:           "YELLOW",
+				Soul:            "1",
+				Level:           "0",
+				Cost:            "0",
+				FlavorText:      "All it takes is something small for people to change the way we think and act... That's all it took for us.",
+				Power:           "1000",
+				Rarity:          "R",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/b/bd_en_w03/BD_EN_W03_004.png",
+				Triggers:        []string{},
+				Traits:          []string{"Music", "Hello, Happy World!"},
 				Text: []string{
 					"【AUTO】At the beginning of your climax phase, choose 1 of your 《Music》 characters, and that character gets +1000 power until end of turn.",
 					"【ACT】Brainstorm [(1)【REST】this card] Flip over 4 cards from the top of your deck, and put it into your waiting room. For each climax revealed among those cards, draw up to 1 card.",
 				},
-				Version: CardModelVersion,
+				HasBrainstorm: true,
+				Version:       CardModelVersion,
 			},
 		},
 		{
@@ -922,26 +956,28 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
       </div>`,
 			English,
 			Card{
-				CardNumber:    "WS/TCPR-P01",
-				SetID:         "WS",
-				ExpansionName: "PR Card 【Weiẞ Side】",
-				Side:          "W",
-				Release:       "TCPR",
-				ReleasePackID: "",
-				ID:            "P01",
-				Language:      "en",
-				Type:          "CX",
-				Name:          "Idol Theme Cup 2024",
-				Color:         "RED",
-				Soul:          "",
-				Level:         "",
-				Cost:          "",
-				FlavorText:    "",
-				Power:         "",
-				Rarity:        "PR",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/updates/PR/WS_TCPR_P01.png",
-				Triggers:      []string{"SOUL", "SOUL"},
-				Traits:        []string{},
+				CardNumber:      "WS/TCPR-P01",
+				SetID:           "WS",
+				ExpansionName:   "PR Card 【Weiẞ Side】",
+				Side:            "W",
+				Release:         "TCPR",
+				ReleasePackID:   "",
+				ReleaseKind:     ReleasePromo,
+				ID:              "P01",
+				CollectorNumber: 1,
+				Language:        "en",
+				Type:            "CX",
+				Name:            "Idol Theme Cup 2024",
+				Color:           "RED",
+				Soul:            "",
+				Level:           "",
+				Cost:            "",
+				FlavorText:      "",
+				Power:           "",
+				Rarity:          "PR",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/updates/PR/WS_TCPR_P01.png",
+				Triggers:        []string{"SOUL", "SOUL"},
+				Traits:          []string{},
 				Text: []string{
 					"【CONT】  All of your characters get +2 soul.",
 				},
@@ -1021,26 +1057,28 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
 			Card{
 				// The website puts the card number as "RWBY/BRO2021-01+PR",
 				// but it's actually "RWBY/BRO2021-01 PR".
-				CardNumber:    "RWBY/BRO2021-01 PR",
-				SetID:         "RWBY",
-				ExpansionName: "PR Card 【Weiẞ Side】",
-				Side:          "W",
-				Release:       "BRO2021",
-				ReleasePackID: "2021",
-				ID:            "01 PR",
-				Language:      "en",
-				Type:          "CH",
-				Name:          "Lie Ren",
-				Color:         "GREEN",
-				Soul:          "1",
-				Level:         "0",
-				Cost:          "0",
-				FlavorText:    "",
-				Power:         "500",
-				Rarity:        "PR",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/RWBY/RWBY_WX03_020PR.png",
-				Triggers:      []string{},
-				Traits:        []string{"Remnant", "JNPR"},
+				CardNumber:      "RWBY/BRO2021-01 PR",
+				SetID:           "RWBY",
+				ExpansionName:   "PR Card 【Weiẞ Side】",
+				Side:            "W",
+				Release:         "BRO2021",
+				ReleasePackID:   "2021",
+				ReleaseKind:     ReleasePromo,
+				ID:              "01 PR",
+				CollectorNumber: 1,
+				Language:        "en",
+				Type:            "CH",
+				Name:            "Lie Ren",
+				Color:           "GREEN",
+				Soul:            "1",
+				Level:           "0",
+				Cost:            "0",
+				FlavorText:      "",
+				Power:           "500",
+				Rarity:          "PR",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/RWBY/RWBY_WX03_020PR.png",
+				Triggers:        []string{},
+				Traits:          []string{"Remnant", "JNPR"},
 				Text: []string{
 					"【AUTO】 When this card becomes 【REVERSE】, if you have another 《Remnant》 character, and this card's battle opponent is level 0 or lower, you may put the top card of your opponent's clock into their waiting room. If you do, put that character into your opponent's clock.",
 					"【AUTO】 [(1)] When this card is put into your waiting room from the stage, you may pay the cost. If you do, look at up to 3 cards from the top of your deck, choose 1 card from among them, put it into your clock, and put the rest into your waiting room. If you put 1 card into your clock, choose 1 《Remnant》 character in your waiting room, and return it to your hand.",
@@ -1119,31 +1157,34 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
       </div>`,
 			English,
 			Card{
-				CardNumber:    "BFR/BSL2021-03S",
-				SetID:         "BFR",
-				ExpansionName: "PR Card 【Schwarz Side】",
-				Side:          "S",
-				Release:       "BSL2021",
-				ReleasePackID: "2021",
-				ID:            "03S",
-				Language:      "en",
-				Type:          "CH",
-				Name:          "Moment Between the Two, Sally",
-				Color:         "BLUE",
-				Soul:          "1",
-				Level:         "1",
-				Cost:          "0",
-				FlavorText:    "",
-				Power:         "4000",
-				Rarity:        "PR",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/updates/PR/BFR_BSL2021_03SPR.png",
-				Triggers:      []string{},
-				Traits:        []string{"Game", "Weapon"},
+				CardNumber:      "BFR/BSL2021-03S",
+				SetID:           "BFR",
+				ExpansionName:   "PR Card 【Schwarz Side】",
+				Side:            "S",
+				Release:         "BSL2021",
+				ReleasePackID:   "2021",
+				ReleaseKind:     ReleasePromo,
+				ID:              "03S",
+				CollectorNumber: 3,
+				Language:        "en",
+				Type:            "CH",
+				Name:            "Moment Between the Two, Sally",
+				Color:           "BLUE",
+				Soul:            "1",
+				Level:           "1",
+				Cost:            "0",
+				FlavorText:      "",
+				Power:           "4000",
+				Rarity:          "PR",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/updates/PR/BFR_BSL2021_03SPR.png",
+				Triggers:        []string{},
+				Traits:          []string{"Game", "Weapon"},
 				Text: []string{
 					"【AUTO】 When your climax is placed on your climax area, this card gets +3000 power until end of turn.",
 					"【AUTO】 【CXCOMBO】 When this card attacks, if \"Never-Ending Sunset Area\" is in your climax area, and you have another 《Game》 character, put the top 2 cards of your deck into your waiting room, choose up to 1 level X or lower 《Game》 character in your waiting room, and return it to your hand. X is equal to the total level of the cards put into your waiting room by this effect. (Climax are regarded as level 0)",
 				},
-				Version: CardModelVersion,
+				HasCXCombo: true,
+				Version:    CardModelVersion,
 			},
 		},
 		{
@@ -1217,26 +1258,28 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
       </div>`,
 			English,
 			Card{
-				CardNumber:    "TSK/S82-E070SSP+",
-				SetID:         "TSK",
-				ExpansionName: "That Time I Got Reincarnated as a Slime Vol.2",
-				Side:          "S",
-				Release:       "S82",
-				ReleasePackID: "82",
-				ID:            "E070SSP+",
-				Language:      "en",
-				Type:          "CH",
-				Name:          "Triumphant Return, Rimuru",
-				Color:         "BLUE",
-				Soul:          "1",
-				Level:         "0",
-				Cost:          "0",
-				FlavorText:    "",
-				Power:         "2000",
-				Rarity:        "SSP+",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/TSK2/TSK_S82_E070S.png",
-				Triggers:      []string{},
-				Traits:        []string{"Demon Continent", "Slime"},
+				CardNumber:      "TSK/S82-E070SSP+",
+				SetID:           "TSK",
+				ExpansionName:   "That Time I Got Reincarnated as a Slime Vol.2",
+				Side:            "S",
+				Release:         "S82",
+				ReleasePackID:   "82",
+				ReleaseKind:     ReleaseStandard,
+				ID:              "E070SSP+",
+				CollectorNumber: 70,
+				Language:        "en",
+				Type:            "CH",
+				Name:            "Triumphant Return, Rimuru",
+				Color:           "BLUE",
+				Soul:            "1",
+				Level:           "0",
+				Cost:            "0",
+				FlavorText:      "",
+				Power:           "2000",
+				Rarity:          "SSP+",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/TSK2/TSK_S82_E070S.png",
+				Triggers:        []string{},
+				Traits:          []string{"Demon Continent", "Slime"},
 				Text: []string{
 					"【AUTO】 When this card is placed on the stage from your hand, reveal the top card of your deck. If that card is a 《Demon Continent》 character, this card gets +1 level and +1500 power until end of turn. (Return the revealed card to its original place)",
 					"【AUTO】 When this card's battle opponent becomes 【REVERSE】, choose 1 of your other 《Demon Continent》 characters, 【REST】 it, and move it to an open position of your back stage.",
@@ -1313,26 +1356,28 @@ func TestExtractData_en_specialCardNumbers(t *testing.T) {
         </div>`,
 			English,
 			Card{
-				CardNumber:    "BD/WE42-E096 N",
-				SetID:         "BD",
-				ExpansionName: "[EX] Bang Dream! Girls Band Party! Countdown Collection",
-				Side:          "W",
-				Release:       "WE42",
-				ReleasePackID: "42",
-				ID:            "E096 N",
-				Language:      "en",
-				Type:          "CH",
-				Name:          "To Stand Side by Side, Sayo Hikawa",
-				Color:         "BLUE",
-				Soul:          "1",
-				Level:         "2",
-				Cost:          "1",
-				FlavorText:    "",
-				Power:         "2500",
-				Rarity:        "N",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/BDCC/WE42_E096_N.png",
-				Triggers:      []string{"SOUL"},
-				Traits:        []string{"Music", "Roselia"},
+				CardNumber:      "BD/WE42-E096 N",
+				SetID:           "BD",
+				ExpansionName:   "[EX] Bang Dream! Girls Band Party! Countdown Collection",
+				Side:            "W",
+				Release:         "WE42",
+				ReleasePackID:   "42",
+				ReleaseKind:     ReleaseSpecial,
+				ID:              "E096 N",
+				CollectorNumber: 96,
+				Language:        "en",
+				Type:            "CH",
+				Name:            "To Stand Side by Side, Sayo Hikawa",
+				Color:           "BLUE",
+				Soul:            "1",
+				Level:           "2",
+				Cost:            "1",
+				FlavorText:      "",
+				Power:           "2500",
+				Rarity:          "N",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/BDCC/WE42_E096_N.png",
+				Triggers:        []string{"SOUL"},
+				Traits:          []string{"Music", "Roselia"},
 				Text: []string{
 					"【AUTO】 [(2) Put 1 character from your stage into your waiting room] When you use this card's \"Backup\", you may pay the cost. If you do, choose 1 of your opponent's characters with level higher than your opponent's level, and put it into their waiting room.",
 					"【ACT】 【COUNTER】 Backup 2500, Level 2 [(1) Put this card from your hand into your waiting room] (Choose 1 of your characters that is being frontal attacked, and that character gets +2500 power until end of turn)",
@@ -1430,26 +1475,28 @@ func TestExtractData_en_improperColor(t *testing.T) {
         </div>`,
 			English,
 			Card{
-				CardNumber:    "SFN/S108-E020",
-				SetID:         "SFN",
-				ExpansionName: "Frieren: Beyond Journey’s End",
-				Side:          "S",
-				Release:       "S108",
-				ReleasePackID: "108",
-				ID:            "E020",
-				Language:      "en",
-				Type:          "CH",
-				Name:          `"Fake Priest?" Heiter`,
-				Color:         "YELLOW",
-				Soul:          "1",
-				Level:         "2",
-				Cost:          "1",
-				FlavorText:    `Himmel: "That brat who said that to me is now a fake priest who just drinks all the time."`,
-				Power:         "4500",
-				Rarity:        "C",
-				ImageURL:      "https://en.ws-tcg.com/wp/wp-content/images/cardimages/SFN/S108_E020.png",
-				Triggers:      []string{"SOUL"},
-				Traits:        []string{"Adventurer", "Magic"},
+				CardNumber:      "SFN/S108-E020",
+				SetID:           "SFN",
+				ExpansionName:   "Frieren: Beyond Journey’s End",
+				Side:            "S",
+				Release:         "S108",
+				ReleasePackID:   "108",
+				ReleaseKind:     ReleaseStandard,
+				ID:              "E020",
+				CollectorNumber: 20,
+				Language:        "en",
+				Type:            "CH",
+				Name:            `"Fake Priest?" Heiter`,
+				Color:           "YELLOW",
+				Soul:            "1",
+				Level:           "2",
+				Cost:            "1",
+				FlavorText:      `Himmel: "That brat who said that to me is now a fake priest who just drinks all the time."`,
+				Power:           "4500",
+				Rarity:          "C",
+				ImageURL:        "https://en.ws-tcg.com/wp/wp-content/images/cardimages/SFN/S108_E020.png",
+				Triggers:        []string{"SOUL"},
+				Traits:          []string{"Adventurer", "Magic"},
 				Text: []string{
 					"【CONT】 Assist All of your characters in front of this card get +X power. X is equal to that character's level ×500.",
 					"【ACT】 [(2) 【REST】 this card] Put the top card of your clock into your waiting room.",
@@ -1470,3 +1517,720 @@ func TestExtractData_en_improperColor(t *testing.T) {
 		assertCardEqualsWithTitle(t, tc.name, card, tc.expectedCard)
 	}
 }
+
+func TestExtractData_en_htmlEntities(t *testing.T) {
+	chara := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-wrapper-inner">
+		<div class="image"><img src="/wp/wp-content/images/cardimages/b/bd_en_w03/BD_EN_W03_004.png" alt="&quot;A Nice Change&quot; Kanon Matsubara" decoding="async">
+		</div>
+		<div class="p-cards__detail-textarea">
+		<p class="number">BD/EN-W03-004</p>
+		<p class="ttl u-mt-14 u-mt-16-sp">&amp;quot;A Nice Change&amp;quot; Kanon &amp;amp; Misaki</p>
+		<div class="p-cards__detail-type u-mt-22 u-mt-40-sp">
+			<dl>
+			<dt>Expansion</dt>
+			<dd>BanG Dream! Girls Band Party! MULTI LIVE &amp;amp; more</dd>
+			</dl>
+		</div>
+		<div class="p-cards__detail u-mt-22 u-mt-40-sp">
+			<p></p>
+		</div>
+		<div class="p-cards__detail-serif u-mt-22 u-mt-40-sp">
+			<p>&quot;Fuee&quot; &amp;amp; more</p>
+		</div>
+		</div>
+	</div>
+</div>
+`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := extractData(siteConfigs[English], doc.Clone())
+	if want := `"A Nice Change" Kanon & Misaki`; card.Name != want {
+		t.Errorf("Incorrect Name: got %q, want %q", card.Name, want)
+	}
+	if want := `BanG Dream! Girls Band Party! MULTI LIVE & more`; card.ExpansionName != want {
+		t.Errorf("Incorrect ExpansionName: got %q, want %q", card.ExpansionName, want)
+	}
+	if want := `"Fuee" & more`; card.FlavorText != want {
+		t.Errorf("Incorrect FlavorText: got %q, want %q", card.FlavorText, want)
+	}
+}
+
+func TestExtractData_en_expansionLink(t *testing.T) {
+	chara := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-textarea">
+	<p class="number">BD/EN-W03-004</p>
+	<p class="ttl u-mt-14 u-mt-16-sp">&quot;A Nice Change&quot; Kanon Matsubara</p>
+	<div class="p-cards__detail-type u-mt-22 u-mt-40-sp">
+		<dl>
+		<dt>Expansion</dt>
+		<dd><a href="/cardlist/searchresults/?expansion=159">BanG Dream! Girls Band Party! MULTI LIVE</a></dd>
+		</dl>
+	</div>
+	</div>
+</div>
+`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := extractData(siteConfigs[English], doc.Selection)
+	if card.ExpansionID != 159 {
+		t.Errorf("got ExpansionID %d, want 159", card.ExpansionID)
+	}
+	if want := "BanG Dream! Girls Band Party! MULTI LIVE"; card.ExpansionName != want {
+		t.Errorf("got ExpansionName %q, want %q", card.ExpansionName, want)
+	}
+}
+
+func TestExtractDataNameWhitespace(t *testing.T) {
+	pages := map[SiteLanguage]string{
+		English: `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-textarea">
+	<p class="number">BD/EN-W03-004</p>
+	<p class="ttl u-mt-14 u-mt-16-sp">
+		Kanon Matsubara&nbsp;
+	</p>
+	</div>
+</div>
+`,
+		Japanese: `
+<tr>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-025&amp;l"><span class="highlight_target">
+	Kanon Matsubara
+	</span>(<span class="highlight_target">BD/W63-025</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br></h4>
+	<span class="unit">種類：クライマックス</span>
+	</td>
+</tr>
+`,
+	}
+	for lang, page := range pages {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		card := extractData(siteConfigs[lang], doc.Selection)
+		if card.Name != "Kanon Matsubara" {
+			t.Errorf("[%v]: got name %q, want %q", lang, card.Name, "Kanon Matsubara")
+		}
+	}
+}
+
+func TestReleaseKind(t *testing.T) {
+	tests := map[string]string{
+		"W63":     ReleaseStandard,
+		"S108":    ReleaseStandard,
+		"BCS2019": ReleasePromo,
+		"BSL2021": ReleasePromo,
+		"TCPR":    ReleasePromo,
+		"WE42":    ReleaseSpecial,
+		"WX04":    ReleaseSpecial,
+		"EN-W03":  ReleaseStandard,
+		"EN-S04":  ReleaseStandard,
+		"PR":      ReleasePromo,
+		"WPR":     ReleasePromo,
+		"SPRX":    ReleaseSpecial,
+		"W1234X":  ReleaseSpecial,
+		"":        "",
+	}
+	for release, want := range tests {
+		if got := releaseKind(release); got != want {
+			t.Errorf("releaseKind(%q) = %q, want %q", release, got, want)
+		}
+	}
+}
+
+func TestMergeCard(t *testing.T) {
+	existing := Card{
+		CardNumber: "BD/W63-025",
+		Name:       "キラキラのお日様",
+		ImageURL:   "https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png",
+		Rarity:     "CR",
+	}
+	fresh := Card{
+		CardNumber: "BD/W63-025",
+		Name:       "キラキラのお日様",
+		Rarity:     "CC",
+	}
+
+	merged := MergeCard(existing, fresh)
+	if merged.ImageURL != existing.ImageURL {
+		t.Errorf("Incorrect ImageURL: got %q, want %q", merged.ImageURL, existing.ImageURL)
+	}
+	if merged.Rarity != fresh.Rarity {
+		t.Errorf("Incorrect Rarity: got %q, want %q", merged.Rarity, fresh.Rarity)
+	}
+
+	fresh.ImageURL = "https://cdn.example.com/bd_w63_025.png"
+	if merged := MergeCard(existing, fresh); merged.ImageURL != fresh.ImageURL {
+		t.Errorf("Incorrect ImageURL: got %q, want %q", merged.ImageURL, fresh.ImageURL)
+	}
+}
+
+func TestMergeCardImageSize(t *testing.T) {
+	existing := Card{CardNumber: "BD/W63-025", ImageURL: "https://ws-tcg.com/bd_w63_025.png", ImageWidth: 400, ImageHeight: 559}
+	fresh := Card{CardNumber: "BD/W63-025", ImageURL: existing.ImageURL}
+	if merged := MergeCard(existing, fresh); merged.ImageWidth != 400 || merged.ImageHeight != 559 {
+		t.Errorf("got %dx%d, want the existing 400x559", merged.ImageWidth, merged.ImageHeight)
+	}
+	fresh.ImageURL = "https://cdn.example.com/bd_w63_025.png"
+	if merged := MergeCard(existing, fresh); merged.ImageWidth != 0 || merged.ImageHeight != 0 {
+		t.Errorf("got %dx%d, a new image shouldn't keep the old size", merged.ImageWidth, merged.ImageHeight)
+	}
+}
+
+func TestParseCard(t *testing.T) {
+	event := `
+	<th><a href="/cardlist/?cardno=BD/W63-022&amp;l"><img src="https://s3-ap-northeast-1.amazonaws.com/static.ws-tcg.com/wordpress/wp-content/cardimages/b/bd_w63/bd_w63_022.gif" alt="ミッシェルからの伝言"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-022&amp;l"><span class="highlight_target">
+	ミッシェルからの伝言</span>(<span class="highlight_target">BD/W63-022</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br></h4>
+	<span class="unit">種類：イベント</span>
+	<span class="highlight_target">あなたは自分の山札の上から2枚を、控え室に置く。</span>
+	</td>
+	`
+	card, err := ParseCard(event, Japanese)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.CardNumber != "BD/W63-022" {
+		t.Errorf("got %v: expected BD/W63-022", card.CardNumber)
+	}
+	if card.Type != "EV" {
+		t.Errorf("got %v: expected EV", card.Type)
+	}
+
+	if _, err := ParseCard("<p>nothing here</p>", English); err == nil {
+		t.Error("expected an error for HTML without a card")
+	}
+	if _, err := ParseCard(event, SiteLanguage(language.French)); err == nil {
+		t.Error("expected an error for an unsupported language")
+	}
+}
+
+func TestSearchName(t *testing.T) {
+	tests := map[string]string{
+		`"A Nice Change" Kanon Matsubara`: "a nice change kanon matsubara",
+		"Pokémon  Café":                   "pokemon cafe",
+		"Aang: Learning Avatar State":     "aang learning avatar state",
+		"“私達、参上っ！”上原ひまり":                  "私達 参上っ 上原ひまり",
+		"バンドリ！ ガールズバンドパーティ！":              "バンドリ ガールズバンドパーティ",
+	}
+	for name, want := range tests {
+		if got := searchName(name); got != want {
+			t.Errorf("searchName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRarity(t *testing.T) {
+	tests := []struct {
+		rarity string
+		name   string
+		tier   int
+	}{
+		{"C", "Common", 2},
+		{"CR", "Climax Rare", 4},
+		{"RR", "Double Rare", 5},
+		{"SR", "Super Rare", 8},
+		{"SSP", "Super Special", 11},
+		{"SSP+", "Super Special+", 12},
+		{"SPMa", "", 0},
+	}
+	for _, tc := range tests {
+		card := Card{Rarity: tc.rarity}
+		if got := card.RarityName(); got != tc.name {
+			t.Errorf("RarityName(%q) = %q, want %q", tc.rarity, got, tc.name)
+		}
+		if got := card.RarityTier(); got != tc.tier {
+			t.Errorf("RarityTier(%q) = %d, want %d", tc.rarity, got, tc.tier)
+		}
+	}
+	if (Card{Rarity: "SR"}).RarityTier() <= (Card{Rarity: "RR"}).RarityTier() {
+		t.Error("Foils should sort above base rarities")
+	}
+	foilTier := (Card{Rarity: "SR"}).RarityTier()
+	for _, rarity := range baseRarity {
+		card := Card{Rarity: rarity}
+		if card.RarityName() == "" {
+			t.Errorf("Base rarity %q has no name", rarity)
+		}
+		if tier := card.RarityTier(); tier == 0 || tier >= foilTier {
+			t.Errorf("Base rarity %q has tier %d: expected between 1 and %d", rarity, tier, foilTier-1)
+		}
+	}
+}
+
+func TestExtractDataFullWidthNumbers_jp(t *testing.T) {
+	chara := `
+	<th><a href="/cardlist/?cardno=BD/W63-036&amp;l"><img src="/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_036.png" alt="上原ひまり"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	<span class="unit">レベル：２</span><br/>
+	<span class="unit">パワー：＋６０００</span>
+	<span class="unit">コスト：１</span><br/>
+	<span class="unit">ソウル：<img src="/wordpress/wp-content/images/cardlist/_partimages/soul.gif"/></span>
+	<span>-</span>
+	</td>
+	`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := extractData(siteConfigs[Japanese], doc.Clone())
+	if card.Power != "6000" {
+		t.Errorf("got %q: expected 6000", card.Power)
+	}
+	if card.Level != "2" {
+		t.Errorf("got %q: expected 2", card.Level)
+	}
+	if card.Cost != "1" {
+		t.Errorf("got %q: expected 1", card.Cost)
+	}
+	if card.Soul != "1" {
+		t.Errorf("got %q: expected 1", card.Soul)
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := map[string]string{
+		"6000":   "6000",
+		"６０００":   "6000",
+		"＋1000":  "1000",
+		" 2 ":    "2",
+		"-":      "",
+		"－":      "",
+		"":       "",
+		"-1000":  "-1000",
+		"1,000?": "1000",
+	}
+	for in, want := range tests {
+		if got := normalizeNumber(in); got != want {
+			t.Errorf("normalizeNumber(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExtractDataPanicOnExtractError(t *testing.T) {
+	broken := `<th></th><td><h4>broken</h4></td>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(broken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recovered by default, and recorded.
+	siteCfg := siteConfigs[Japanese]
+	siteCfg.errors = &scrapeErrors{}
+	extractData(siteCfg, doc.Clone())
+	if errs := siteCfg.errors.list(); len(errs) != 1 || errs[0].Stage != StageExtract {
+		t.Errorf("got errors %v, expected one extract error", errs)
+	}
+
+	siteCfg.panicOnExtractError = true
+	defer func() {
+		if recover() == nil {
+			t.Error("expected extraction to panic")
+		}
+	}()
+	extractData(siteCfg, doc.Clone())
+}
+
+func TestTriggerCounts(t *testing.T) {
+	tests := []struct {
+		triggers []string
+		expected map[string]int
+	}{
+		{[]string{"SOUL", "SOUL"}, map[string]int{"SOUL": 2}},
+		{[]string{"SOUL", "RETURN"}, map[string]int{"SOUL": 1, "RETURN": 1}},
+		{[]string{}, map[string]int{}},
+	}
+	for _, tt := range tests {
+		got := Card{Triggers: tt.triggers}.TriggerCounts()
+		if !maps.Equal(got, tt.expected) {
+			t.Errorf("TriggerCounts(%v) = %v: expected %v", tt.triggers, got, tt.expected)
+		}
+	}
+}
+
+func TestIsbaseRarityPlusSuffix(t *testing.T) {
+	if IsbaseRarity(Card{ID: "E070SSP+", Rarity: "RR+"}) {
+		t.Error("E070SSP+ is a foil and shouldn't be a base rarity")
+	}
+	if isTrullyNotFoil(Card{ID: "E070SSP+"}) {
+		t.Error("expected E070SSP+ to be a foil")
+	}
+	if !isTrullyNotFoil(Card{ID: "E070"}) {
+		t.Error("expected E070 not to be a foil")
+	}
+}
+
+func TestGrantedSoul(t *testing.T) {
+	tests := []struct {
+		name   string
+		card   Card
+		soul   int
+		exists bool
+	}{
+		{"Idol Theme Cup", Card{Type: "CX", Text: []string{"【CONT】  All of your characters get +2 soul."}}, 2, true},
+		{"キラキラのお日様", Card{Type: "CX", Text: []string{
+			"【永】 あなたのキャラすべてに、パワーを＋1000し、ソウルを＋1。",
+			"（[RETURN]：このカードがトリガーした時、あなたは相手のキャラを1枚選び、手札に戻してよい）",
+		}}, 1, true},
+		{"full-width", Card{Type: "CX", Text: []string{"【永】 あなたのキャラすべてに、ソウルを＋２。"}}, 2, true},
+		{"no boost", Card{Type: "CX", Text: []string{"【CONT】  All of your characters get +1000 power."}}, 0, false},
+		{"character", Card{Type: "CH", Soul: "1", Text: []string{"【AUTO】 This card gets +1 soul."}}, 0, false},
+	}
+	for _, tt := range tests {
+		soul, ok := tt.card.GrantedSoul()
+		if soul != tt.soul || ok != tt.exists {
+			t.Errorf("[%s]: got %d, %v: expected %d, %v", tt.name, soul, ok, tt.soul, tt.exists)
+		}
+	}
+}
+
+func TestEnCardType(t *testing.T) {
+	tests := map[string]string{
+		"Character":      "CH",
+		"character":      "CH",
+		"Character Card": "CH",
+		"Event":          "EV",
+		"EVENT CARD":     "EV",
+		"Climax Card":    "CX",
+		" climax ":       "CX",
+		"Card":           "",
+		"Marker":         "",
+	}
+	for in, expected := range tests {
+		if got := enCardType(in); got != expected {
+			t.Errorf("enCardType(%q) = %q: expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestExtractData_en_extraFields(t *testing.T) {
+	chara := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-wrapper-inner">
+		<div class="image"><img src="/wp/wp-content/images/cardimages/b/bd_en_w03/BD_EN_W03_004.png" alt="Kanon Matsubara" decoding="async">
+		</div>
+		<div class="p-cards__detail-textarea">
+		<p class="number">BD/EN-W03-004</p>
+		<p class="ttl u-mt-14 u-mt-16-sp">Kanon Matsubara</p>
+		<div class="p-cards__detail-type u-mt-22 u-mt-40-sp">
+			<dl>
+			<dt>Expansion</dt>
+			<dd>BanG Dream! Girls Band Party! MULTI LIVE</dd>
+			</dl>
+			<dl>
+			<dt>Illustrator</dt>
+			<dd> Someone &amp;amp; Co </dd>
+			</dl>
+			<dl>
+			<dt>Product</dt>
+			<dd>Premium Booster</dd>
+			</dl>
+		</div>
+		<div class="p-cards__detail u-mt-22 u-mt-40-sp">
+			<p></p>
+		</div>
+		</div>
+	</div>
+</div>
+`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := extractData(siteConfigs[English], doc.Clone())
+	expected := map[string]string{
+		"Illustrator": "Someone & Co",
+		"Product":     "Premium Booster",
+	}
+	if !maps.Equal(card.ExtraFields, expected) {
+		t.Errorf("got extra fields %v: expected %v", card.ExtraFields, expected)
+	}
+	if _, ok := card.ExtraFields["Expansion"]; ok {
+		t.Error("known detail Expansion shouldn't be in the extra fields")
+	}
+}
+
+func TestExtractData_en_multilineFlavor(t *testing.T) {
+	chara := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-wrapper-inner">
+		<div class="p-cards__detail-textarea">
+		<p class="number">BD/EN-W03-004</p>
+		<p class="ttl u-mt-14 u-mt-16-sp">Kanon Matsubara</p>
+		<div class="p-cards__detail u-mt-22 u-mt-40-sp">
+			<p></p>
+		</div>
+		<div class="p-cards__detail-serif u-mt-22 u-mt-40-sp">
+			<p>"Fuee..."<br>
+			"I-I'll do my best!"</p>
+		</div>
+		</div>
+	</div>
+</div>
+`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := extractData(siteConfigs[English], doc.Clone())
+	if want := "\"Fuee...\"\n\"I-I'll do my best!\""; card.FlavorText != want {
+		t.Errorf("Incorrect FlavorText: got %q, want %q", card.FlavorText, want)
+	}
+}
+
+func TestCardKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     []string
+		expected []string
+	}{
+		{
+			"Aang",
+			[]string{
+				"【CONT】 If your climax area has a climax with [CHOICE] in its trigger icon, this card in all of your zones get [CHOICE] in the trigger icon.",
+				"【AUTO】 【CLOCK】 Alarm If this card is the top card of your clock, and you have 4 or more 《World of Avatar》 characters, at the beginning of your climax phase, you may put the top card of your deck into your stock.",
+			},
+			[]string{"Alarm"},
+		},
+		{
+			"Brainstorm",
+			[]string{
+				"【AUTO】At the beginning of your climax phase, choose 1 of your 《Music》 characters, and that character gets +1000 power until end of turn.",
+				"【ACT】Brainstorm [(1)【REST】this card] Flip over 4 cards from the top of your deck, and put it into your waiting room. For each climax revealed among those cards, draw up to 1 card.",
+			},
+			[]string{"Brainstorm"},
+		},
+		{
+			"JP",
+			[]string{
+				"【自】【CXコンボ】 このカードのバトルしているキャラが【リバース】した時、あなたはそのキャラを山札の下に置いてよい。",
+				"【自】 アンコール ［手札のキャラを１枚控え室に置く］",
+			},
+			[]string{"Encore", "CXCOMBO"},
+		},
+		{
+			"Exchange isn't Change",
+			[]string{"【AUTO】 Exchange this card with a card in your hand."},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := cardKeywords(tt.text); !equalSlice(got, tt.expected) {
+			t.Errorf("%s: got %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestExtractData_en_paddedCardNumber(t *testing.T) {
+	chara := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-wrapper-inner">
+		<div class="p-cards__detail-textarea">
+		<p class="number">
+			<span>  FS/BCS2019-03
+			</span>
+		</p>
+		<p class="ttl u-mt-14 u-mt-16-sp">Nice Guy Wantaro</p>
+		<div class="p-cards__detail u-mt-22 u-mt-40-sp">
+			<p></p>
+		</div>
+		</div>
+	</div>
+</div>
+`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := extractData(siteConfigs[English], doc.Clone())
+	if card.CardNumber != "FS/BCS2019-03" {
+		t.Errorf("Incorrect CardNumber: got %q, want %q", card.CardNumber, "FS/BCS2019-03")
+	}
+	if card.Release != "BCS2019" || card.ID != "03" {
+		t.Errorf("Incorrect Release/ID: got %q/%q, want BCS2019/03", card.Release, card.ID)
+	}
+}
+
+func TestSanitizeCardNumberWhitespace(t *testing.T) {
+	tests := map[string]string{
+		" BD/W63-036 \n":        "BD/W63-036",
+		"RWBY/BRO2021-01+PR":    "RWBY/BRO2021-01 PR",
+		"RWBY/BRO2021-01  \tPR": "RWBY/BRO2021-01 PR",
+	}
+	for in, want := range tests {
+		if got := sanitizeCardNumber(in); got != want {
+			t.Errorf("sanitizeCardNumber(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseCardNumberJpPromo(t *testing.T) {
+	tests := []struct {
+		cardNumber                        string
+		setID, release, releasePackID, id string
+	}{
+		{"DC3/W55-PR-01", "DC3", "W55", "55", "PR-01"},
+		{"KS/W49-P-01", "KS", "W49", "49", "P-01"},
+		{"BD/W63-PR01", "BD", "W63", "63", "PR01"},
+		{"IMS/S21-P01", "IMS", "S21", "21", "P01"},
+		{"BD/SY01-001", "BD", "SY01", "01", "001"},
+		{"BD/W63-036SPMa", "BD", "W63", "63", "036SPMa"},
+	}
+	for _, tt := range tests {
+		setID, release, releasePackID, id := parseCardNumber(tt.cardNumber)
+		if setID != tt.setID || release != tt.release || releasePackID != tt.releasePackID || id != tt.id {
+			t.Errorf("parseCardNumber(%q) = %q, %q, %q, %q: want %q, %q, %q, %q", tt.cardNumber,
+				setID, release, releasePackID, id, tt.setID, tt.release, tt.releasePackID, tt.id)
+		}
+	}
+}
+
+func TestExtractData_noImage(t *testing.T) {
+	en := `
+<div class="p-cards__detail-wrapper">
+	<div class="p-cards__detail-wrapper-inner">
+		<div class="p-cards__detail-textarea">
+		<p class="number">WS/TCPR-P01</p>
+		<p class="ttl u-mt-14 u-mt-16-sp">Idol Theme Cup 2024</p>
+		<div class="p-cards__detail u-mt-22 u-mt-40-sp">
+			<p></p>
+		</div>
+		</div>
+	</div>
+</div>
+`
+	jp := `
+	<th></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	`
+	for lang, page := range map[SiteLanguage]string{English: en, Japanese: jp} {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		card := extractData(siteConfigs[lang], doc.Clone())
+		if card.CardNumber == "" {
+			t.Errorf("%v: card wasn't extracted", lang)
+		}
+		if card.ImageURL != "" {
+			t.Errorf("%v: got ImageURL %q, want empty", lang, card.ImageURL)
+		}
+	}
+}
+
+func TestCollectorNumber(t *testing.T) {
+	tests := []struct {
+		id     string
+		number int
+		ok     bool
+	}{
+		{"036SPMa", 36, true},
+		{"E070SSP+", 70, true},
+		{"P01", 1, true},
+		{"01 PR", 1, true},
+		{"T12", 12, true},
+		{"SP", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if n, ok := collectorNumber(tt.id); n != tt.number || ok != tt.ok {
+			t.Errorf("collectorNumber(%q) = %d, %v: expected %d, %v", tt.id, n, ok, tt.number, tt.ok)
+		}
+	}
+}
+
+func TestExtractDataJpWrappings(t *testing.T) {
+	cells := `
+	<th><a href="/cardlist/?cardno=BD/W63-025&amp;l"><img src="/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png" alt="キラキラのお日様"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-025&amp;l"><span class="highlight_target">
+	キラキラのお日様</span>(<span class="highlight_target">BD/W63-025</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br></h4>
+	<span class="unit">種類：クライマックス</span>
+	<span class="unit">トリガー：<img src="/wordpress/wp-content/images/cardlist/_partimages/soul.gif"></span>
+	<span class="unit">特徴：<span class="highlight_target">-</span></span><br>
+	<span class="unit">フレーバー：楽しい気持ちは誰かといると生まれるものってこと！</span><br>
+	%s
+	</td>`
+	ability := `<span class="highlight_target">【永】 あなたのキャラすべてに、パワーを＋1000し、ソウルを＋1。</span>`
+	wrappings := map[string]string{
+		"bare":  "%s",
+		"tr":    "<tr>%s</tr>",
+		"table": `<table class="search-result-table"><tr>%s</tr></table>`,
+	}
+
+	for _, withAbility := range []bool{true, false} {
+		abilityHTML := ""
+		if withAbility {
+			abilityHTML = ability
+		}
+		var cards []Card
+		for name, wrapping := range wrappings {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(fmt.Sprintf(wrapping, fmt.Sprintf(cells, abilityHTML))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sel := doc.Find(".search-result-table tr")
+			if sel.Length() == 0 {
+				sel = doc.Selection
+			}
+			card := extractData(siteConfigs[Japanese], sel)
+			if withAbility && !equalSlice(card.Text, []string{"【永】 あなたのキャラすべてに、パワーを＋1000し、ソウルを＋1。"}) {
+				t.Errorf("[%s]: got text %q", name, card.Text)
+			}
+			if !withAbility && len(card.Text) != 0 {
+				t.Errorf("[%s]: got text %q for a card without abilities", name, card.Text)
+			}
+			cards = append(cards, card)
+		}
+		for _, card := range cards[1:] {
+			assertCardEquals(t, card, cards[0])
+		}
+	}
+}
+
+func TestCardEqual(t *testing.T) {
+	a := Card{CardNumber: "BD/W63-036", Traits: []string{"音楽"}, Text: nil}
+	b := Card{CardNumber: "BD/W63-036", Traits: []string{"音楽"}, Text: []string{}}
+	if a.Equal(b) {
+		t.Error("expected cards with nil and empty Text to differ")
+	}
+	b.Text = nil
+	if !a.Equal(b) {
+		t.Error("expected the same cards to be equal")
+	}
+	b.Traits = []string{"音楽", "Afterglow"}
+	if a.Equal(b) {
+		t.Error("expected cards with different Traits to differ")
+	}
+	b = a
+	b.ExtraFields = map[string]string{"Illustrator": "someone"}
+	if a.Equal(b) {
+		t.Error("expected cards with different ExtraFields to differ")
+	}
+}
@@ -16,18 +16,29 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"path"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/publicsuffix"
@@ -50,141 +61,192 @@ const (
 	// The minimum amount of time each worker should wait before making a new request to the server. This should help to avoid overwhelming the server.
 	minTimeBetweenRequests = 500 * time.Millisecond
 
+	// How long to wait for the first search page when working out the last page.
+	lastPageTimeout = 30 * time.Second
+
+	// The number of cards on an EN search result page when the page size
+	// can't be worked out.
+	enDefaultCardsPerPage = 15
+
+	// The most pages countPages follows before giving up.
+	maxCountedPages = 500
+
+	// The default spread of the page fetch workers' start.
+	defaultWorkerStartJitter = 100 * time.Millisecond
+
 	// Constants for retry logic
-	maxRetries       = 3
-	baseBackoffDelay = 1 * time.Second
+	maxRetries = 3
 )
 
+// baseBackoffDelay is the wait before the first retry of a request, growing
+// with every attempt. It's a variable so tests don't sleep through it.
+var baseBackoffDelay = 1 * time.Second
+
+// jpExpansionOnclickRE gets the first numeric argument of the JP recent
+// releases onclick, e.g. 444 from showExpansionDetail('444') or
+// showExpansionDetail("444", 1).
+var jpExpansionOnclickRE = regexp.MustCompile(`\(\s*['"]?(\d+)['"]?\s*[,)]`)
+
+// enResultLinks returns the detail page links of an EN search result page. The
+// text layout (view=text) is a list with one link per card. If the site
+// ignores view=text and sends the gallery layout instead, fall back to every
+// link to a card detail page.
+func enResultLinks(doc *goquery.Document) []string {
+	var links []string
+	doc.Find(".p_cards__results-box ul li").Each(func(i int, s *goquery.Selection) {
+		if href, ok := s.Find("a").First().Attr("href"); ok {
+			links = append(links, href)
+		} else {
+			slog.Error("Result item without a link")
+		}
+	})
+	if len(links) > 0 {
+		return links
+	}
+
+	seen := make(map[string]bool)
+	doc.Find(`a[href*="cardno="]`).Each(func(i int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		if !seen[href] {
+			seen[href] = true
+			links = append(links, href)
+		}
+	})
+	if len(links) > 0 {
+		slog.Warn("Search results aren't in the text layout, using the card links")
+	}
+	return links
+}
+
 type SiteLanguage language.Tag
 
 func (s SiteLanguage) String() string {
 	return language.Tag(s).String()
 }
 
+// MarshalText renders the language as its tag, e.g. "ja", in JSON.
+func (s SiteLanguage) MarshalText() ([]byte, error) {
+	return language.Tag(s).MarshalText()
+}
+
 var (
 	English  SiteLanguage = SiteLanguage(language.English)
 	Japanese SiteLanguage = SiteLanguage(language.Japanese)
 )
 
 type siteConfig struct {
-	baseURL                    string
-	baseURLValues              func() url.Values
-	cardListURL                string
-	cardSearchURL              string
+	baseURL          string
+	baseURLValues    func() url.Values
+	cardListURL      string
+	cardSearchURL    string
+	cardNumberValues func(cardNumber string) url.Values
+	// cardDetailURL is the card permalink page, which takes a cardno
+	// parameter. Empty if the site's detail page can't be extracted.
+	cardDetailURL              string
+	detailSelector             string
 	languageCode               language.Tag
 	lastPageFunc               func(doc *goquery.Document) int
-	pageScanParseFunc          func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- *goquery.Selection, resp *http.Response) (pageDone bool)
+	pageScanParseFunc          func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- cardSelection, resp *http.Response) (pageDone bool)
 	recentReleaseDistinguisher string
 	recentRelaseExpansionFunc  func(page *goquery.Selection) *url.Values
-	supportTitleNumber         bool
+	// resultCountFunc returns the total number of results of a search page.
+	// Nil if the site doesn't show it.
+	resultCountFunc func(doc *goquery.Document) (int, error)
+	// pageItemsFunc returns the number of cards on a search page and
+	// nextPageSelector finds its pager links, see hasNextPage. countPages
+	// uses them when lastPageFunc returns 0 because it can't tell.
+	pageItemsFunc      func(doc *goquery.Document) int
+	nextPageSelector   string
+	supportTitleNumber bool
+	// triggers maps the site's trigger icon file names to trigger names.
+	triggers map[string]string
+	// textTriggers replaces triggers in the ability text, see
+	// Config.TriggerLabels. Nil uses triggers.
+	textTriggers map[string]string
+	// typeValues maps CardTypes to the search's card_kind values. Nil if the
+	// search can't filter by type.
+	typeValues map[string]string
+	// resultSelectors must be on a search results page, see CheckSite.
+	resultSelectors []string
+	// pageSize is the number of cards on a full search page, to estimate the
+	// cards of a search when resultCountFunc can't tell. 0 if unknown.
+	pageSize int
+
+	// panicOnExtractError is copied from Config.PanicOnExtractError.
+	panicOnExtractError bool
+	// errors collects the extraction errors of a run. Nil ignores them.
+	errors *scrapeErrors
 }
 
+// expansionHrefRE gets the expansion number from EN links to an expansion's
+// search results.
+var expansionHrefRE = regexp.MustCompile(`expansion=(\d+)`)
+
 var siteConfigs = map[SiteLanguage]siteConfig{
 	English: {
 		baseURL: "https://en.ws-tcg.com/",
 		baseURLValues: func() url.Values {
 			return url.Values{
 				"view": {"text"},
+				// Ask for bigger pages like the JP site. The site may ignore it,
+				// so lastPageFunc works out the page size from the results.
+				"show_page_count": {"100"},
 			}
 		},
 		cardListURL:   "https://en.ws-tcg.com/cardlist/",
 		cardSearchURL: "https://en.ws-tcg.com/cardlist/searchresults/",
-		languageCode:  language.English,
+		cardNumberValues: func(cardNumber string) url.Values {
+			return url.Values{
+				"view":           {"text"},
+				"parallel":       {"0"},
+				"keyword_or":     {cardNumber},
+				"keyword_type[]": {"no"},
+			}
+		},
+		cardDetailURL:   "https://en.ws-tcg.com/cardlist/list/",
+		resultCountFunc: enResultCount,
+		pageItemsFunc: func(doc *goquery.Document) int {
+			return len(enResultLinks(doc))
+		},
+		nextPageSelector: ".c-pager a",
+		resultSelectors:  []string{".c-search__results-item"},
+		detailSelector:   ".p-cards__detail-wrapper",
+		languageCode:     language.English,
 		lastPageFunc: func(doc *goquery.Document) int {
-			numCardsS := doc.Find(".c-search__results-item span").First().Text()
-			numCards, err := strconv.Atoi(numCardsS)
+			numCards, err := enResultCount(doc)
 			if err != nil {
-				slog.Error(fmt.Sprintf("Couldn't get num cards: %v", err))
-				return 1
+				slog.Warn(fmt.Sprintf("Couldn't get num cards, counting the pages instead: %v", err))
+				return 0
+			}
+			// Use the number of cards on this first page as the page size, in
+			// case the site honours show_page_count. As of 2024-9-3, it's 15.
+			perPage := doc.Find(".p_cards__results-box ul li").Length()
+			if perPage == 0 {
+				perPage = enDefaultCardsPerPage
 			}
-			// As of 2024-9-3, there are 15 cards per "page".
-			// TODO: figure out a better way to get the total number of pages
-			return (numCards-1)/15 + 1
+			return (numCards-1)/perPage + 1
 		},
-		pageScanParseFunc: func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- *goquery.Selection, resp *http.Response) (pageDone bool) {
+		pageScanParseFunc: func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- cardSelection, resp *http.Response) (pageDone bool) {
 			doc, err := goquery.NewDocumentFromReader(resp.Body)
 			if err != nil {
-				task.pageURLCh <- resp.Request.URL.String()
 				slog.With("url", resp.Request.URL).Error(fmt.Sprintf("Couldn't parse result page: %v", err))
+				task.retryPage(resp, fmt.Sprintf("couldn't parse result page: %v", err))
 				return false
 			}
-			resultList := doc.Find(".p_cards__results-box ul li")
+			subPaths := enResultLinks(doc)
 
-			if resultList.Length() == 0 && resp.StatusCode == http.StatusOK {
+			if len(subPaths) == 0 && resp.StatusCode == http.StatusOK {
 				slog.With("url", resp.Request.URL).Warn("No cards on response page")
 			} else {
 				slog.With("url", resp.Request.URL).Debug("Found cards!")
-				resultList.Each(func(i int, s *goquery.Selection) {
-					subPath, exists := s.Find("a").First().Attr("href")
-					if !exists {
-						slog.With("url", resp.Request.URL).Error(fmt.Sprintf("Error getting sub path: %v", err))
-						return
-					}
+				for _, subPath := range subPaths {
 					fp, err := joinPath(task.siteConfig.baseURL, subPath)
 					if err != nil {
 						slog.With("url", resp.Request.URL).Error(fmt.Sprintf("Error getting full path: %v", err))
-						return
-					}
-					fullPath := fp.String()
-
-					proxy := biri.GetClient()
-					proxy.Client.Jar = task.cookieJar
-
-					transport, ok := proxy.Client.Transport.(*http.Transport)
-					if !ok {
-						transport = &http.Transport{}
-					}
-					// Skip verification since we're targeting a trusted site
-					transport.TLSClientConfig = &tls.Config{
-						InsecureSkipVerify: true,
-					}
-					transport.DisableKeepAlives = false
-
-					proxy.Client.Transport = transport
-
-					t := time.After(minTimeBetweenRequests)
-					// Retry logic for EOF errors
-					var detailedPageResp *http.Response
-					for retries := 0; retries < maxRetries; retries++ {
-						if retries > 0 {
-							backoffDelay := time.Duration(retries) * baseBackoffDelay
-							jitter := time.Duration(rand.Int63n(int64(backoffDelay) / 2))
-							time.Sleep(backoffDelay + jitter)
-						}
-
-						detailedPageResp, err = proxy.Client.Get(fullPath)
-						if err == nil && detailedPageResp.StatusCode == http.StatusOK {
-							break
-						}
-						if detailedPageResp != nil {
-							detailedPageResp.Body.Close()
-						}
+						continue
 					}
-
-					if err != nil || detailedPageResp.StatusCode != http.StatusOK {
-						var sc string
-						if detailedPageResp != nil {
-							sc = fmt.Sprintf(" (statusCode=%d)", detailedPageResp.StatusCode)
-							detailedPageResp.Body.Close()
-						}
-						slog.With("url", fullPath).Error(fmt.Sprintf("Failed to get detailed page%s", sc), "error", err)
-					} else {
-						defer detailedPageResp.Body.Close()
-						proxy.Readd()
-						doc, err := goquery.NewDocumentFromReader(detailedPageResp.Body)
-						if err != nil {
-							// TODO: add proper retry of failed pages
-							slog.With("url", detailedPageResp.Request.URL).Error(fmt.Sprintf("Couldn't parse detailedPageResp: %v", err))
-							return
-						}
-						slog.With("url", fullPath).Debug("Successfully parsed detailed page")
-						cardDetails := doc.Find(".p-cards__detail-wrapper")
-						wgCardSel.Add(1)
-						cardSelCh <- cardDetails
-					}
-					// Force the wait between requests
-					<-t
-				})
+					task.scrapeDetail(fp, wgCardSel, cardSelCh)
+				}
 			}
 
 			return true
@@ -192,8 +254,7 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 		recentReleaseDistinguisher: "div.p-cards__latest-products ul.c-product__list a",
 		recentRelaseExpansionFunc: func(sel *goquery.Selection) *url.Values {
 			if hrefAttr, exists := sel.Attr("href"); exists {
-				re := regexp.MustCompile(`expansion=(\d+)`)
-				if m := re.FindStringSubmatch(hrefAttr); m != nil {
+				if m := expansionHrefRE.FindStringSubmatch(hrefAttr); m != nil {
 					return &url.Values{
 						"view":      {"text"},
 						"expansion": {m[1]},
@@ -203,6 +264,7 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 			return nil
 		},
 		supportTitleNumber: true,
+		triggers:           triggersMap,
 	},
 	Japanese: {
 		baseURL: "https://ws-tcg.com/",
@@ -215,7 +277,17 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 		},
 		cardListURL:   "https://ws-tcg.com/cardlist/",
 		cardSearchURL: "https://ws-tcg.com/cardlist/search",
-		languageCode:  language.Japanese,
+		cardNumberValues: func(cardNumber string) url.Values {
+			return url.Values{
+				"cmd":             {"search"},
+				"show_page_count": {"100"},
+				"show_small":      {"0"},
+				"parallel":        {"0"},
+				"keyword":         {cardNumber},
+				"keyword_type[]":  {"no"},
+			}
+		},
+		languageCode: language.Japanese,
 		lastPageFunc: func(doc *goquery.Document) int {
 			all := doc.Find(".pager .next")
 
@@ -226,11 +298,17 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 			}
 			return last
 		},
-		pageScanParseFunc: func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- *goquery.Selection, resp *http.Response) (pageDone bool) {
+		pageScanParseFunc: func(task *scrapeTask, wgCardSel *sync.WaitGroup, cardSelCh chan<- cardSelection, resp *http.Response) (pageDone bool) {
 			doc, err := goquery.NewDocumentFromReader(resp.Body)
 			if err != nil {
-				task.pageURLCh <- resp.Request.URL.String()
 				slog.With("url", resp.Request.URL).Error(fmt.Sprintf("Couldn't parse result page: %v", err))
+				task.retryPage(resp, fmt.Sprintf("couldn't parse result page: %v", err))
+				return false
+			}
+			if doc.Find(".search-result-table").Length() == 0 {
+				// A 200 without the results table is usually a proxy's "blocked" page.
+				slog.With("url", resp.Request.URL).Warn("Result page has no result table, retrying")
+				task.retryPage(resp, "no result table")
 				return false
 			}
 			resultTable := doc.Find(".search-result-table tr")
@@ -239,9 +317,11 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 				slog.With("url", resp.Request.URL).Warn("No cards on response page")
 			} else {
 				slog.With("url", resp.Request.URL).Debug("Found cards!")
+				retries := pageRetries(resp)
 				resultTable.Each(func(i int, s *goquery.Selection) {
 					wgCardSel.Add(1)
-					cardSelCh <- s
+					atomic.AddInt64(&task.cardsFound, 1)
+					cardSelCh <- cardSelection{sel: s, url: resp.Request.URL.String(), task: task, retries: retries}
 				})
 			}
 
@@ -252,21 +332,26 @@ var siteConfigs = map[SiteLanguage]siteConfig{
 			onclickAttr, exists := sel.Attr("onclick")
 			if exists {
 				// Extract the integer value from the onclick attribute
-				parts := strings.Split(onclickAttr, "('")
-				if len(parts) >= 2 {
-					value := strings.TrimSuffix(parts[1], "')")
+				if m := jpExpansionOnclickRE.FindStringSubmatch(onclickAttr); m != nil {
 					return &url.Values{
 						"cmd":             {"search"},
 						"show_page_count": {"100"},
 						"show_small":      {"0"},
 						"parallel":        {"0"},
-						"expansion":       {value},
+						"expansion":       {m[1]},
 					}
 				}
+				slog.Warn(fmt.Sprintf("Couldn't get expansion from onclick %q", onclickAttr))
 			}
 			return nil
 		},
 		supportTitleNumber: false,
+		triggers:           triggersMap,
+		// The search form's card_kind select.
+		typeValues: map[string]string{"CH": "2", "EV": "3", "CX": "4"},
+		// baseURLValues' show_page_count.
+		pageSize:        100,
+		resultSelectors: []string{".search-result-table"},
 	},
 }
 
@@ -285,28 +370,603 @@ type scrapeTask struct {
 	cookieJar  http.CookieJar
 	lastPage   int
 	wgPageScan *sync.WaitGroup
+	seen       *seenCards
+	progress   *progressTracker
+	rng        *lockedRand
+
+	// resultCount is the number of cards the site says the search has, or 0
+	// if it doesn't say.
+	resultCount int
+	// deadline is when to stop fetching new pages. Zero means no deadline.
+	deadline time.Time
+	// robots skips the pages robots.txt disallows. Nil allows everything.
+	robots *robotsRules
+	// failures is shared by all the tasks of a run.
+	failures *failureTracker
+	// errors is shared by all the tasks of a run. Nil ignores them.
+	errors *scrapeErrors
+	// httpClient replaces the proxies when set, see Config.HTTPClient.
+	httpClient *http.Client
+	// skipCardNumber is Config.SkipCardNumber.
+	skipCardNumber func(cardNumber string) bool
+	// pageStart is Config.PageStart.
+	pageStart int
+	// useGET is Config.UseGET.
+	useGET bool
+
+	// removed lists the detail pages of cards no longer on the site. Nil
+	// ignores them.
+	removed *removedCards
+	// requeues counts the tries of the pages put back in the queue, see
+	// requeuePage.
+	requeues *pageRequeues
+	// counted keeps the pages countPages fetched. Nil if it didn't run.
+	counted *countedPages
+	// catalog is shared by all the tasks of a run, see warnIfUnfiltered.
+	catalog *catalogSizes
+
+	// Updated atomically by the workers.
+	cardsFound   int64
+	cardsSkipped int64
+	pagesScanned int64
+	pagesSkipped int64
+	parallels    int64
+	start        time.Time
+	end          time.Time
+}
+
+// removedCards collects the detail pages of removed cards from the workers.
+type removedCards struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+// add records url. It does nothing on a nil removedCards.
+func (r *removedCards) add(url string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = append(r.urls, url)
+}
+
+// list returns the pages recorded so far.
+func (r *removedCards) list() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.urls)
+}
+
+// expansion returns the expansion number the task searches, if it searches a
+// single one.
+func (s *scrapeTask) expansion() (int, bool) {
+	if len(s.urlValues["expansion"]) != 1 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s.urlValues.Get("expansion"))
+	return n, err == nil
+}
+
+// stats summarizes the task once it's done.
+func (s *scrapeTask) stats() TaskStats {
+	ts := TaskStats{
+		Expansion:    s.urlValues.Get("expansion"),
+		Query:        s.urlValues.Encode(),
+		CardsFound:   int(atomic.LoadInt64(&s.cardsFound)),
+		PagesScanned: int(atomic.LoadInt64(&s.pagesScanned)),
+		Parallels:    int(atomic.LoadInt64(&s.parallels)),
+		Removed:      s.removed.list(),
+		Duration:     s.end.Sub(s.start),
+	}
+	ts.ExpectedCards = s.expectedCards()
+	if ts.ExpectedCards > 0 {
+		got := ts.CardsFound + int(atomic.LoadInt64(&s.cardsSkipped)) + len(ts.Removed)
+		ts.MissingCards = max(ts.ExpectedCards-got, 0)
+	}
+	return ts
+}
+
+// shortfallRatio is the share of the expected cards a task has to miss for
+// the scrape to warn about it.
+const shortfallRatio = 0.1
+
+// expectedCards returns how many cards the search should return: the count
+// the site shows, or else the cards on the full pages, the last one may not
+// be. It returns 0 when it can't tell, including when pages were left out on
+// purpose.
+func (s *scrapeTask) expectedCards() int {
+	if s.lastPage == 0 || s.pageStart > 1 {
+		return 0
+	}
+	if s.resultCount > 0 {
+		return s.resultCount
+	}
+	return (s.lastPage - 1) * s.siteConfig.pageSize
+}
+
+// shortfall reports whether the task missed a substantial part of its
+// expected cards, e.g. detail pages the proxies never got.
+func (ts TaskStats) shortfall() bool {
+	return ts.MissingCards > 0 && float64(ts.MissingCards) >= shortfallRatio*float64(ts.ExpectedCards)
+}
+
+// TaskStats describes one search run by CardsStream, usually one expansion.
+type TaskStats struct {
+	// Expansion is the expansion number searched, if any.
+	Expansion string
+	// Query is the encoded search parameters.
+	Query        string
+	CardsFound   int
+	PagesScanned int
+	// Parallels is how many of the cards sent are parallel (foil) versions,
+	// e.g. to check that GetAllRarities actually returned them.
+	Parallels int
+	// Removed lists the detail pages of cards that are no longer on the site.
+	// They are skipped rather than counted as errors.
+	Removed []string
+	// ExpectedCards is how many cards the search should have returned, 0 if
+	// unknown. MissingCards is how many of them were neither found, skipped
+	// nor removed, e.g. because their detail page failed.
+	ExpectedCards int
+	MissingCards  int
+	Duration      time.Duration
+}
+
+// lockedRand is a *rand.Rand that's safe to share between the workers.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newLockedRand returns a source seeded with seed, or a random seed if it's 0.
+func newLockedRand(seed int64) *lockedRand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+// Int63n is rand.Int63n. A nil lockedRand uses the global source.
+func (l *lockedRand) Int63n(n int64) int64 {
+	if l == nil {
+		return rand.Int63n(n)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// Progress is what's been done so far in a CardsStream run.
+type Progress struct {
+	PagesScanned int
+	// TotalPages is 0 until the last page of every search is known.
+	TotalPages int
+	Cards      int
+}
+
+// progressTracker reports progress to Config.OnProgress. A nil tracker does
+// nothing.
+type progressTracker struct {
+	mu sync.Mutex
+	p  Progress
+	fn func(Progress)
+}
+
+func newProgressTracker(fn func(Progress)) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn}
+}
+
+// update changes the progress with f and reports it. Calls are serialized.
+func (t *progressTracker) update(f func(p *Progress)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.p)
+	t.fn(t.p)
+}
+
+// failureTracker counts the pages whose fetch failed all its retries in a row
+// across all workers and trips once there are too many. A nil tracker never trips.
+type failureTracker struct {
+	max         int64
+	consecutive int64
+	tripped     atomic.Bool
+}
+
+func newFailureTracker(max int) *failureTracker {
+	if max <= 0 {
+		return nil
+	}
+	return &failureTracker{max: int64(max)}
+}
+
+// failure records a page whose fetch failed all its retries.
+func (t *failureTracker) failure() {
+	if t == nil {
+		return
+	}
+	if atomic.AddInt64(&t.consecutive, 1) >= t.max && !t.tripped.Swap(true) {
+		slog.Error(fmt.Sprintf("%d pages failed in a row, aborting", t.max))
+	}
+}
+
+// success resets the count of failures in a row.
+func (t *failureTracker) success() {
+	if t == nil {
+		return
+	}
+	atomic.StoreInt64(&t.consecutive, 0)
+}
+
+// aborted reports whether the tracker tripped.
+func (t *failureTracker) aborted() bool {
+	return t != nil && t.tripped.Load()
+}
+
+// ScrapeStats describes a whole CardsStream run.
+type ScrapeStats struct {
+	Tasks []TaskStats
+	// Errors lists the pages and cards the run gave up on.
+	Errors []ScrapeError
+}
+
+// Values for ScrapeError.Stage.
+const (
+	// StagePage is a search result page that was never fetched.
+	StagePage = "page"
+	// StageDetail is an EN detail page that couldn't be fetched.
+	StageDetail = "detail"
+	// StageExtract is a card whose extraction panicked.
+	StageExtract = "extract"
+)
+
+// ScrapeError is a page or card a run gave up on.
+type ScrapeError struct {
+	URL        string `json:"url,omitempty"`
+	CardNumber string `json:"cardNumber,omitempty"`
+	Stage      string `json:"stage"`
+	Message    string `json:"message"`
+}
+
+// scrapeErrors collects the ScrapeErrors of a run from the workers.
+type scrapeErrors struct {
+	mu   sync.Mutex
+	errs []ScrapeError
+}
+
+// add records e. It does nothing on a nil scrapeErrors.
+func (s *scrapeErrors) add(e ScrapeError) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, e)
+}
+
+// list returns the errors recorded so far.
+func (s *scrapeErrors) list() []ScrapeError {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.errs)
+}
+
+// Parallels is the number of parallel cards sent by all the tasks.
+func (s ScrapeStats) Parallels() int {
+	total := 0
+	for _, task := range s.Tasks {
+		total += task.Parallels
+	}
+	return total
+}
+
+// cardSelection is a card's HTML waiting to be extracted, along with where it
+// came from.
+type cardSelection struct {
+	sel  *goquery.Selection
+	url  string
+	task *scrapeTask
+	// retries is how many retries fetching the page sel comes from took.
+	retries int
+}
+
+// seenCards records the card numbers extracted by a scrape task and the page
+// they were found on, so duplicates (usually overlapping pages) can be reported.
+type seenCards struct {
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+func newSeenCards() *seenCards {
+	return &seenCards{urls: make(map[string]string)}
+}
+
+// mark records cardNumber as found at url. If the card was already seen, it
+// returns the URL it was first found at and true.
+func (s *seenCards) mark(cardNumber, url string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.urls[cardNumber]; ok {
+		return prev, true
+	}
+	s.urls[cardNumber] = url
+	return "", false
 }
 
 func (s *scrapeTask) getLastPage() (int, error) {
 	slog.Info(fmt.Sprintf("Getting last page of %q with %v", s.siteConfig.cardSearchURL, s.urlValues))
-	resp, err := http.PostForm(fmt.Sprintf("%v?page=%d", s.siteConfig.cardSearchURL, 1), s.urlValues)
+	if !s.robots.allowed(s.siteConfig.cardSearchURL) {
+		return 0, fmt.Errorf("robots.txt disallows the search page %v", s.siteConfig.cardSearchURL)
+	}
+	client := s.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: lastPageTimeout, Jar: s.cookieJar}
+	}
+	resp, err := search(client, fmt.Sprintf("%v?page=%d", s.siteConfig.cardSearchURL, 1), s.urlValues, s.useGET)
 	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return 0, fmt.Errorf("timed out after %v getting last page of %q", lastPageTimeout, s.siteConfig.cardSearchURL)
+		}
 		return 0, fmt.Errorf("error getting last page: %v", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("error getting last page: unexpected status code %v", resp.StatusCode)
+	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error reading last page: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return 0, fmt.Errorf("error parsing last page: %v", err)
 	}
 
 	last := s.siteConfig.lastPageFunc(doc)
+	if last == 0 {
+		s.counted = &countedPages{pages: make(map[string]*http.Response)}
+		s.counted.add(fmt.Sprintf("%v?page=%d", s.siteConfig.cardSearchURL, 1), resp, body)
+		last = s.countPages(doc)
+	}
+	if s.siteConfig.resultCountFunc != nil {
+		if count, err := s.siteConfig.resultCountFunc(doc); err == nil {
+			s.resultCount = count
+		}
+	}
+	if s.urlValues.Has("expansion") || s.urlValues.Has("expansion_name") {
+		s.warnIfUnfiltered(client, doc)
+	}
 
 	slog.With("url", resp.Request.URL).Info(fmt.Sprintf("Last page is %d for %v", last, s.urlValues))
 	s.lastPage = last
 	return last, nil
 }
 
+// countPages works out the last page of the search by fetching the pages one
+// after the other, from first, while they link to a later page, or are as
+// full as the first one when they have no pager. It's for when lastPageFunc can't tell, e.g. when the EN
+// site doesn't show the number of results. The pages are fetched like the
+// page fetch workers do, and kept in s.counted so they aren't fetched again.
+func (s *scrapeTask) countPages(first *goquery.Document) int {
+	if s.siteConfig.pageItemsFunc == nil {
+		return 1
+	}
+	pageSize := s.siteConfig.pageItemsFunc(first)
+	doc, last := first, 1
+	for pageSize > 0 && last < maxCountedPages {
+		if doc.Find(s.siteConfig.nextPageSelector).Length() > 0 {
+			if !s.hasNextPage(doc, last) {
+				break
+			}
+		} else if s.siteConfig.pageItemsFunc(doc) < pageSize {
+			break
+		}
+		link := fmt.Sprintf("%v?page=%d", s.siteConfig.cardSearchURL, last+1)
+		if !s.robots.allowed(link) {
+			slog.Warn(fmt.Sprintf("Stopped counting pages at %d: page %d is disallowed by robots.txt", last, last+1))
+			break
+		}
+		time.Sleep(minTimeBetweenRequests)
+		next, err := s.fetchCountedPage(link)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Stopped counting pages at %d: %v", last, err))
+			break
+		}
+		if s.siteConfig.pageItemsFunc(next) == 0 {
+			break
+		}
+		doc = next
+		last++
+	}
+	slog.Info(fmt.Sprintf("Counted %d pages for %v", last, s.urlValues))
+	return last
+}
+
+// hasNextPage reports whether doc, the search page number page, links to a
+// later page.
+func (s *scrapeTask) hasNextPage(doc *goquery.Document, page int) bool {
+	hasNext := false
+	doc.Find(s.siteConfig.nextPageSelector).EachWithBreak(func(i int, a *goquery.Selection) bool {
+		n, err := strconv.Atoi(strings.TrimSpace(a.Text()))
+		if u, uerr := url.Parse(a.AttrOr("href", "")); uerr == nil && u.Query().Has("page") {
+			n, err = strconv.Atoi(u.Query().Get("page"))
+		}
+		hasNext = err == nil && n > page
+		return !hasNext
+	})
+	return hasNext
+}
+
+// fetchCountedPage fetches the search page link for countPages, with the
+// same retries and proxy handling as pageFetchWorker, and keeps it in
+// s.counted.
+func (s *scrapeTask) fetchCountedPage(link string) (*goquery.Document, error) {
+	var err error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * baseBackoffDelay)
+		}
+		client, ok, bad := s.pageClient()
+		var resp *http.Response
+		resp, err = search(client, link, s.urlValues, s.useGET)
+		if err != nil {
+			bad()
+			continue
+		}
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("bad status code=%v", resp.StatusCode)
+		}
+		var doc *goquery.Document
+		if err == nil {
+			doc, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
+		}
+		if err != nil {
+			bad()
+			continue
+		}
+		ok()
+		s.counted.add(link, resp, body)
+		return doc, nil
+	}
+	return nil, err
+}
+
+// countedPages keeps the search pages fetched by countPages until the page
+// fetch workers get to them.
+type countedPages struct {
+	mu    sync.Mutex
+	pages map[string]*http.Response
+}
+
+// add keeps resp, whose body was already read into body, as the page link.
+func (c *countedPages) add(link string, resp *http.Response, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	c.pages[link] = resp
+}
+
+// take returns the page link and forgets it, or nil if it isn't kept. It
+// returns nil on a nil countedPages.
+func (c *countedPages) take(link string) *http.Response {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := c.pages[link]
+	delete(c.pages, link)
+	return resp
+}
+
+// search sends the search values to link like the site's form does, or as a
+// GET with the values in the query if useGET is set, see Config.UseGET. The
+// values replace any of the same name already in link, so a link taken from a
+// GET response can be searched again.
+func search(client *http.Client, link string, values url.Values, useGET bool) (*http.Response, error) {
+	if !useGET {
+		return client.PostForm(link, values)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for k, v := range values {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+	return client.Get(u.String())
+}
+
+// warnIfUnfiltered logs a warning when the search in doc has as many results
+// as the whole catalog, which means the site ignored the expansion filter.
+func (s *scrapeTask) warnIfUnfiltered(client *http.Client, doc *goquery.Document) {
+	if s.siteConfig.resultCountFunc == nil {
+		return
+	}
+	count, err := s.siteConfig.resultCountFunc(doc)
+	if err != nil {
+		return
+	}
+
+	parallel := s.urlValues.Get("parallel")
+	total, err := s.catalog.size(parallel, func() (int, error) {
+		return s.catalogSize(client, parallel)
+	})
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Couldn't get the catalog size: %v", err))
+		return
+	}
+	if total == count {
+		slog.Warn(fmt.Sprintf("The search for %v returned the whole catalog (%d cards), the expansion filter may not be applied", s.urlValues, total))
+	}
+}
+
+// catalogSize searches the whole catalog to get its number of cards.
+func (s *scrapeTask) catalogSize(client *http.Client, parallel string) (int, error) {
+	catalogValues := s.siteConfig.baseURLValues()
+	catalogValues.Set("parallel", parallel)
+	resp, err := search(client, fmt.Sprintf("%v?page=%d", s.siteConfig.cardSearchURL, 1), catalogValues, s.useGET)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	catalogDoc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't parse the catalog: %v", err)
+	}
+	return s.siteConfig.resultCountFunc(catalogDoc)
+}
+
+// catalogSizes remembers the number of cards of the whole catalog by
+// parallel search value, so it's only searched once per run.
+type catalogSizes struct {
+	mu    sync.Mutex
+	sizes map[string]catalogSizeResult
+}
+
+type catalogSizeResult struct {
+	count int
+	err   error
+}
+
+// size returns the catalog size for parallel, calling fetch the first time.
+// A nil catalogSizes calls fetch every time.
+func (c *catalogSizes) size(parallel string, fetch func() (int, error)) (int, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.sizes[parallel]; ok {
+		return r.count, r.err
+	}
+	count, err := fetch()
+	if c.sizes == nil {
+		c.sizes = make(map[string]catalogSizeResult)
+	}
+	c.sizes[parallel] = catalogSizeResult{count, err}
+	return count, err
+}
+
 func getTasksForRecentReleases(siteCfg siteConfig, doc *goquery.Document) []scrapeTask {
 	var tasks []scrapeTask
 	// Find all <a> elements with onclick attributes within the <ul> element
@@ -319,6 +979,31 @@ func getTasksForRecentReleases(siteCfg siteConfig, doc *goquery.Document) []scra
 	return tasks
 }
 
+// retryTargetCardNumber returns the card number for a retry target, which is
+// either a card number or a detail page URL with a cardno parameter.
+func retryTargetCardNumber(target string) string {
+	if u, err := url.Parse(target); err == nil {
+		if cn := u.Query().Get("cardno"); cn != "" {
+			return cn
+		}
+	}
+	return strings.TrimSpace(target)
+}
+
+// getTasksForRetryTargets makes one search task per target, each searching
+// for exactly that card number.
+func getTasksForRetryTargets(siteCfg siteConfig, targets []string) []scrapeTask {
+	var tasks []scrapeTask
+	for _, target := range targets {
+		cn := retryTargetCardNumber(target)
+		if cn == "" {
+			continue
+		}
+		tasks = append(tasks, scrapeTask{urlValues: siteCfg.cardNumberValues(cn)})
+	}
+	return tasks
+}
+
 func joinPath(baseURL, subPath string) (*url.URL, error) {
 	b, err := url.Parse(baseURL)
 	if err != nil {
@@ -331,51 +1016,359 @@ func joinPath(baseURL, subPath string) (*url.URL, error) {
 	return b.ResolveReference(sp), nil
 }
 
-func pageFetchWorker(id int, task *scrapeTask) {
+// rebaseURL moves rawURL onto the scheme and host of baseURL, prefixing the
+// base's path if it has one.
+func rebaseURL(rawURL, baseURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse URL: %v", err)
+	}
+	b, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse base URL: %v", err)
+	}
+	u.Scheme = b.Scheme
+	u.Host = b.Host
+	u.Path = path.Join("/", b.Path, u.Path)
+	return u.String(), nil
+}
+
+// pageClient returns the client to fetch a search page with, along with
+// callbacks to report whether it worked.
+func (s *scrapeTask) pageClient() (*http.Client, func(), func()) {
+	if s.httpClient != nil {
+		return s.httpClient, func() {}, func() {}
+	}
+	proxy := biri.GetClient()
+	proxy.Client.Jar = s.cookieJar
+	return proxy.Client, proxy.Readd, proxy.Ban
+}
+
+// detailClient returns a client set up for fetching detail pages, along with
+// callbacks to report whether it worked.
+func detailClient(task *scrapeTask) (*http.Client, func(), func()) {
+	if task.httpClient != nil {
+		return task.httpClient, func() {}, func() {}
+	}
+	proxy := biri.GetClient()
+	proxy.Client.Jar = task.cookieJar
+
+	transport, ok := proxy.Client.Transport.(*http.Transport)
+	if !ok {
+		transport = &http.Transport{}
+	}
+	// Skip verification since we're targeting a trusted site
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true,
+	}
+	transport.DisableKeepAlives = false
+
+	proxy.Client.Transport = transport
+	return proxy.Client, proxy.Readd, proxy.Ban
+}
+
+// errCardRemoved is returned by fetchDetailPage for a card that's no longer on
+// the site, which retrying won't fix.
+var errCardRemoved = errors.New("card removed")
+
+// cardRemoved reports whether a detail page answered with status and details
+// is for a card that was removed: the page is gone, or it still has the
+// details block but no card number in it.
+func cardRemoved(status int, details *goquery.Selection) bool {
+	if status == http.StatusNotFound || status == http.StatusGone {
+		return true
+	}
+	return details != nil && details.Length() > 0 && strings.TrimSpace(details.Find(".number").Text()) == ""
+}
+
+// fetchDetailPage gets a card detail page and returns its card details block
+// along with how many retries it took.
+// A page that comes back 200 without the block is usually a proxy's "blocked"
+// page, so the proxy is banned and the page retried.
+func fetchDetailPage(task *scrapeTask, fullPath string) (*goquery.Selection, int, error) {
+	if !task.robots.allowed(fullPath) {
+		return nil, 0, fmt.Errorf("disallowed by robots.txt")
+	}
+	var lastErr error
+	for retries := 0; retries < maxRetries; retries++ {
+		if retries > 0 {
+			backoffDelay := time.Duration(retries) * baseBackoffDelay
+			jitter := time.Duration(task.rng.Int63n(int64(backoffDelay) / 2))
+			time.Sleep(backoffDelay + jitter)
+		}
+
+		client, ok, bad := detailClient(task)
+		resp, err := client.Get(fullPath)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if cardRemoved(resp.StatusCode, nil) {
+			resp.Body.Close()
+			ok()
+			return nil, retries, errCardRemoved
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("bad status code=%d", resp.StatusCode)
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("couldn't parse detailed page: %v", err)
+			continue
+		}
+		details := doc.Find(task.siteConfig.detailSelector)
+		if details.Length() == 0 {
+			slog.With("url", fullPath).Warn("Detailed page has no card details, banning proxy")
+			bad()
+			lastErr = fmt.Errorf("no %q on detailed page", task.siteConfig.detailSelector)
+			continue
+		}
+		ok()
+		if cardRemoved(resp.StatusCode, details) {
+			return nil, retries, errCardRemoved
+		}
+		return details, retries, nil
+	}
+	return nil, 0, fmt.Errorf("failed after %d attempts: %v", maxRetries, lastErr)
+}
+
+// scrapeDetail fetches the detail page fp and sends it to cardSelCh, unless
+// its card is skipped, was removed or the fetch fails.
+func (s *scrapeTask) scrapeDetail(fp *url.URL, wgCardSel *sync.WaitGroup, cardSelCh chan<- cardSelection) {
+	fullPath := fp.String()
+	if s.skipCardNumber != nil {
+		if cn := fp.Query().Get("cardno"); cn != "" && s.skipCardNumber(sanitizeCardNumber(cn)) {
+			slog.With("url", fullPath).Debug("Skipping card before its detail page")
+			atomic.AddInt64(&s.cardsSkipped, 1)
+			return
+		}
+	}
+
+	t := time.After(minTimeBetweenRequests)
+	cardDetails, retries, err := fetchDetailPage(s, fullPath)
+	if errors.Is(err, errCardRemoved) {
+		slog.With("url", fullPath).Warn("Card removed from the site, skipping")
+		s.removed.add(fullPath)
+	} else if err != nil {
+		slog.With("url", fullPath).Error("Failed to get detailed page", "error", err)
+		s.errors.add(ScrapeError{URL: fullPath, Stage: StageDetail, Message: err.Error()})
+	} else {
+		slog.With("url", fullPath).Debug("Successfully parsed detailed page")
+		wgCardSel.Add(1)
+		atomic.AddInt64(&s.cardsFound, 1)
+		cardSelCh <- cardSelection{sel: cardDetails, url: fullPath, task: s, retries: retries}
+	}
+	// Force the wait between requests
+	<-t
+}
+
+// streamDetailPages fetches the detail pages at urls, e.g. from the sitemap,
+// with one pool of workers instead of a search per card, and sends their
+// cards to cardCh.
+func streamDetailPages(cfg Config, task *scrapeTask, dates *releaseDateIndex, urls []string, cardCh chan<- Card) (ScrapeStats, error) {
+	var stats ScrapeStats
+	task.seen = newSeenCards()
+	task.removed = &removedCards{}
+
+	var wgCardSel sync.WaitGroup
+	cardSelCh := make(chan cardSelection, cfg.localWorkers())
+	for i := 0; i < cfg.localWorkers(); i++ {
+		go extractWorker(task.siteConfig, cfg, dates, &wgCardSel, cardSelCh, cardCh)
+	}
+
+	urlCh := make(chan string)
+	var wgFetch sync.WaitGroup
+	task.start = time.Now()
+	for i := 0; i < cfg.scrapeWorkers(); i++ {
+		wgFetch.Add(1)
+		go func(startDelay time.Duration) {
+			defer wgFetch.Done()
+			time.Sleep(startDelay)
+			for link := range urlCh {
+				if !task.deadline.IsZero() && time.Now().After(task.deadline) {
+					task.errors.add(ScrapeError{URL: link, Stage: StageDetail, Message: "skipped, max duration exceeded"})
+					atomic.AddInt64(&task.pagesSkipped, 1)
+					continue
+				}
+				if task.failures.aborted() {
+					task.errors.add(ScrapeError{URL: link, Stage: StageDetail, Message: "skipped after too many failures"})
+					continue
+				}
+				fp, err := url.Parse(link)
+				if err != nil {
+					task.errors.add(ScrapeError{URL: link, Stage: StageDetail, Message: err.Error()})
+					continue
+				}
+				task.scrapeDetail(fp, &wgCardSel, cardSelCh)
+			}
+		}(cfg.startDelay(task.rng))
+	}
+	for _, link := range urls {
+		urlCh <- link
+	}
+	close(urlCh)
+	wgFetch.Wait()
+	task.end = time.Now()
+	wgCardSel.Wait()
+	close(cardSelCh)
+
+	ts := task.stats()
+	slog.Info("Detail pages done", "cards", ts.CardsFound, "removed", len(ts.Removed), "duration", ts.Duration)
+	stats.Tasks = append(stats.Tasks, ts)
+	stats.Errors = task.errors.list()
+	if task.failures.aborted() {
+		return stats, fmt.Errorf("aborted after %d pages failed in a row", cfg.MaxConsecutiveFailures)
+	}
+	if skipped := atomic.LoadInt64(&task.pagesSkipped); skipped > 0 {
+		return stats, fmt.Errorf("stopped after %v with %d cards left: %w", cfg.MaxDuration, skipped, context.DeadlineExceeded)
+	}
+	return stats, nil
+}
+
+// pageFetchKey is the request context key holding the pageFetch of a result
+// page.
+type pageFetchKey struct{}
+
+// pageFetch is how pageFetchWorker got a result page.
+type pageFetch struct {
+	// link is the queued link of the page.
+	link string
+	// retries is how many retries fetching the page took.
+	retries int
+	// bad bans the proxy that served the page.
+	bad func()
+}
+
+// pageRetries returns how many retries fetching the result page of resp took.
+func pageRetries(resp *http.Response) int {
+	f, _ := resp.Request.Context().Value(pageFetchKey{}).(pageFetch)
+	return f.retries
+}
+
+// maxPageRequeues is how many times a result page is put back in the queue
+// before its task gives up on it.
+const maxPageRequeues = 3
+
+// pageRequeues counts how many times each result page of a task was put back
+// in the queue.
+type pageRequeues struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// inc counts a requeue of link and returns how many there were so far.
+func (r *pageRequeues) inc(link string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[link]++
+	return r.counts[link]
+}
+
+// requeuePage puts link back in the queue to fetch it again, or gives up on
+// it after maxPageRequeues tries, recording reason. Without a requeue counter
+// the page is always put back.
+func (s *scrapeTask) requeuePage(link, reason string) {
+	if s.requeues != nil && s.requeues.inc(link) > maxPageRequeues {
+		slog.With("url", link).Error(fmt.Sprintf("Giving up on page after %d tries: %v", maxPageRequeues, reason))
+		s.errors.add(ScrapeError{URL: link, Stage: StagePage, Message: reason})
+		s.wgPageScan.Done()
+		return
+	}
+	s.pageURLCh <- link
+}
+
+// retryPage bans the proxy that served the page of resp, e.g. because it's a
+// block page, and requeues the page.
+func (s *scrapeTask) retryPage(resp *http.Response, reason string) {
+	f, _ := resp.Request.Context().Value(pageFetchKey{}).(pageFetch)
+	if f.bad != nil {
+		f.bad()
+	}
+	link := f.link
+	if link == "" {
+		link = resp.Request.URL.String()
+	}
+	s.requeuePage(link, reason)
+}
+
+func pageFetchWorker(id int, task *scrapeTask, startDelay time.Duration) {
+	// Stagger the workers so they don't all hit the proxies at once.
+	time.Sleep(startDelay)
 	for link := range task.pageURLCh {
+		if !task.deadline.IsZero() && time.Now().After(task.deadline) {
+			// Out of time, drop the page but let the scan finish.
+			slog.With("url", link).Debug("Skipping page, max duration exceeded")
+			task.errors.add(ScrapeError{URL: link, Stage: StagePage, Message: "skipped, max duration exceeded"})
+			atomic.AddInt64(&task.pagesSkipped, 1)
+			task.wgPageScan.Done()
+			continue
+		}
+		if !task.robots.allowed(link) {
+			task.wgPageScan.Done()
+			continue
+		}
+		if task.failures.aborted() {
+			slog.With("url", link).Debug("Skipping page, too many failures")
+			task.errors.add(ScrapeError{URL: link, Stage: StagePage, Message: "skipped after too many failures"})
+			task.wgPageScan.Done()
+			continue
+		}
+		if resp := task.counted.take(link); resp != nil {
+			// countPages already fetched it.
+			resp.Request = resp.Request.WithContext(context.WithValue(context.Background(), pageFetchKey{}, pageFetch{link: link, bad: func() {}}))
+			task.pageRespCh <- resp
+			continue
+		}
 		success := false
 		var errs []string
 
 		// Try up to maxRetries times with exponential backoff
-		for attempt := 0; attempt < maxRetries; attempt++ {
+		for attempt := 0; attempt < maxRetries && !task.failures.aborted(); attempt++ {
 			if attempt > 0 {
 				// Exponential backoff with jitter
 				backoffDelay := time.Duration(attempt) * baseBackoffDelay
-				jitter := time.Duration(rand.Int63n(int64(backoffDelay) / 2))
+				jitter := time.Duration(task.rng.Int63n(int64(backoffDelay) / 2))
 				waitTime := backoffDelay + jitter
 				slog.Debug(fmt.Sprintf("Retry attempt %d for %s, waiting %v", attempt, link, waitTime))
 				time.Sleep(waitTime)
 			}
 
 			slog.Debug(fmt.Sprintf("ID %d: fetching page %q with params %v", id, link, task.urlValues))
-			proxy := biri.GetClient()
-			proxy.Client.Jar = task.cookieJar
+			client, ok, bad := task.pageClient()
 
 			t := time.After(minTimeBetweenRequests)
-			resp, err := proxy.Client.PostForm(link, task.urlValues)
+			resp, err := search(client, link, task.urlValues, task.useGET)
 			if err != nil {
 				if strings.Contains(err.Error(), "connection reset by peer") ||
 					strings.Contains(err.Error(), "EOF") ||
 					strings.Contains(err.Error(), "connection refused") {
 					slog.With("url", link).Debug("Temporary connection error", "error", err, "attempt", attempt)
-					proxy.Ban()
+					bad()
 					continue
 				}
 				slog.With("url", link).Debug("Proxy error", "error", err, "attempt", attempt)
-				proxy.Ban()
+				bad()
 				continue // Try next attempt
 			}
 
 			if resp.StatusCode != http.StatusOK {
 				errs = append(errs, fmt.Sprintf("Bad status code=%v, attempt=%d", resp.StatusCode, attempt))
 				resp.Body.Close()
-				proxy.Ban()
+				bad()
 				continue // Try next attempt
 			}
 
 			// Success
-			proxy.Readd()
-			resp.Request = resp.Request.WithContext(context.Background()) // Use a new context without timeout
+			task.failures.success()
+			ok()
+			// Use a new context without timeout
+			resp.Request = resp.Request.WithContext(context.WithValue(context.Background(), pageFetchKey{}, pageFetch{link: link, retries: attempt, bad: bad}))
 			task.pageRespCh <- resp
 			<-t // Force wait between requests
 			success = true
@@ -387,7 +1380,10 @@ func pageFetchWorker(id int, task *scrapeTask) {
 			for _, err := range errs {
 				slog.With("url", link).Error(err)
 			}
-			task.pageURLCh <- link // Put back in queue for later
+			// Count the page once, not every attempt, so a single bad page
+			// doesn't abort the run.
+			task.failures.failure()
+			task.requeuePage(link, "failed all retry attempts") // Put back in queue for later
 		}
 	}
 	slog.Info(fmt.Sprintf("Page fetch worker %d done", id))
@@ -397,11 +1393,13 @@ func pageScanWorker(
 	id int,
 	task *scrapeTask,
 	wgCardSel *sync.WaitGroup,
-	cardSelCh chan<- *goquery.Selection,
+	cardSelCh chan<- cardSelection,
 ) {
 	for resp := range task.pageRespCh {
 		slog.Debug(fmt.Sprintf("Start scanning page: %v", resp.Request.URL))
 		if task.siteConfig.pageScanParseFunc(task, wgCardSel, cardSelCh, resp) {
+			atomic.AddInt64(&task.pagesScanned, 1)
+			task.progress.update(func(p *Progress) { p.PagesScanned++ })
 			task.wgPageScan.Done()
 		}
 		resp.Body.Close()
@@ -410,55 +1408,220 @@ func pageScanWorker(
 	slog.Info(fmt.Sprintf("Page scan worker %d done", id))
 }
 
-func getImage(url string) (image.Image, error) {
-	var img image.Image
-	var err error
+// imageTimeout bounds a single image request made without a proxy.
+const imageTimeout = 30 * time.Second
 
+// fetchImageData downloads the image at url, retrying with backoff. getClient
+// returns the client to use for an attempt along with callbacks to report
+// whether it worked. A response that doesn't decode as an image (e.g. a
+// proxy's block page) counts as a failure.
+func fetchImageData(url string, interval time.Duration, getClient func() (client *http.Client, ok, bad func())) ([]byte, error) {
+	var err error
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			backoffDelay := time.Duration(attempt) * baseBackoffDelay
 			time.Sleep(backoffDelay)
 		}
 
-		client := biri.GetClient()
-		t := time.After(minTimeBetweenRequests)
+		client, ok, bad := getClient()
+		t := time.After(interval)
 		var resp *http.Response
-		resp, err = client.Client.Get(url)
+		resp, err = client.Get(url)
 		// Force the wait between requests
 		<-t
 
 		if err != nil {
-			client.Ban()
+			bad()
 			continue
 		}
-
-		img, _, err = image.Decode(resp.Body)
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
 		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			// The proxy worked, the image just isn't there: retrying won't help.
+			ok()
+			return nil, fmt.Errorf("%w: %s", errImageNotFound, url)
+		}
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("bad status code=%d", resp.StatusCode)
+		}
+		if err == nil {
+			_, _, err = image.DecodeConfig(bytes.NewReader(data))
+		}
+		if err != nil {
+			bad()
+			continue
+		}
+		ok()
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("failed to get image after %d attempts: %v", maxRetries, err)
+}
+
+// errImageNotFound is returned by fetchImageData when the server answers 404.
+var errImageNotFound = errors.New("image not found")
+
+// enImagePath and jpImagePath are where each site keeps its card images.
+const (
+	enImagePath = "/wp/wp-content/images/cardimages/"
+	jpImagePath = "/wordpress/wp-content/images/cardlist/"
+)
+
+// alternateImageURL returns the JP site URL of an EN card image. Some EN cards
+// reuse the JP art and are missing from the EN host, but the JP one has them
+// under the same path in lowercase.
+func alternateImageURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "en.ws-tcg.com" || !strings.HasPrefix(u.Path, enImagePath) {
+		return "", false
+	}
+	u.Host = "ws-tcg.com"
+	u.Path = jpImagePath + strings.ToLower(strings.TrimPrefix(u.Path, enImagePath))
+	return u.String(), true
+}
+
+// fetchImageWithFallback is fetchImageData, trying alternateImageURL when the
+// image isn't found at imageURL.
+func fetchImageWithFallback(imageURL string, interval time.Duration, getClient func() (*http.Client, func(), func())) ([]byte, error) {
+	data, err := fetchImageData(imageURL, interval, getClient)
+	if !errors.Is(err, errImageNotFound) {
+		return data, err
+	}
+	alt, ok := alternateImageURL(imageURL)
+	if !ok {
+		return nil, err
+	}
+	data, altErr := fetchImageData(alt, interval, getClient)
+	if altErr != nil {
+		return nil, fmt.Errorf("%v, alternate: %v", err, altErr)
+	}
+	slog.Info("Image found on alternate host", "url", imageURL, "alternate", alt)
+	return data, nil
+}
+
+// httpClient returns a copy of HTTPClient using jar if it has no jar of its
+// own, or nil to use the proxies.
+func (cfg Config) httpClient(jar http.CookieJar) *http.Client {
+	if cfg.HTTPClient == nil {
+		return nil
+	}
+	client := *cfg.HTTPClient
+	if client.Jar == nil {
+		client.Jar = jar
+	}
+	return &client
+}
+
+// imageClient returns the client for fetchImageData: HTTPClient if set, else a
+// proxy.
+func (cfg Config) imageClient() (*http.Client, func(), func()) {
+	if cfg.HTTPClient != nil {
+		return cfg.HTTPClient, func() {}, func() {}
+	}
+	return proxyImageClient()
+}
 
-		if err == nil {
-			client.Readd()
-			return img, nil
-		}
+// proxyImageClient gets a proxy from biri for fetchImageData.
+func proxyImageClient() (*http.Client, func(), func()) {
+	proxy := biri.GetClient()
+	return proxy.Client, proxy.Readd, proxy.Ban
+}
 
-		client.Ban()
+// getImage downloads and decodes the image at url with the clients from
+// getClient, waiting at least interval between requests.
+func getImage(url string, interval time.Duration, getClient func() (*http.Client, func(), func())) (image.Image, error) {
+	data, err := fetchImageWithFallback(url, interval, getClient)
+	if err != nil {
+		return nil, err
 	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
 
-	return nil, fmt.Errorf("failed to get image after %d attempts: %v", maxRetries, err)
+// proxiesRunning counts the CardsStream runs using the proxies, so
+// DownloadImage knows whether it can get one.
+var proxiesRunning atomic.Int32
+
+// DownloadImage returns the raw bytes of the image at url, with the same
+// retries, backoff and alternate host used when fetching cards, waiting the
+// cfg.ImageRequestInterval between requests. It uses cfg.HTTPClient if set,
+// else the proxies while a CardsStream runs them. Otherwise it connects
+// directly, images come from a CDN that doesn't need the proxies.
+func DownloadImage(url string, cfg Config) ([]byte, error) {
+	direct := &http.Client{Timeout: imageTimeout}
+	return fetchImageWithFallback(url, cfg.imageInterval(), func() (*http.Client, func(), func()) {
+		if cfg.HTTPClient == nil && !cfg.DirectConnection && proxiesRunning.Load() > 0 {
+			return proxyImageClient()
+		}
+		if cfg.HTTPClient != nil {
+			return cfg.HTTPClient, func() {}, func() {}
+		}
+		return direct, func() {}, func() {}
+	})
 }
 
-func extractWorker(siteCfg siteConfig, getImages bool, wgCardSel *sync.WaitGroup, cardSelChan <-chan *goquery.Selection, cardCh chan<- Card) {
+func extractWorker(siteCfg siteConfig, cfg Config, releaseDates *releaseDateIndex, wgCardSel *sync.WaitGroup, cardSelChan <-chan cardSelection, cardCh chan<- Card) {
 	for s := range cardSelChan {
-		c := extractData(siteCfg, s)
+		c := extractData(siteCfg, s.sel)
+		c.RetryCount = s.retries
+		if c.ExpansionID == 0 && s.task != nil {
+			c.ExpansionID, _ = s.task.expansion()
+		}
+		c.ReleaseDate = releaseDates.date(c.Release)
+		if cfg.ImageBaseURL != "" && c.ImageURL != "" {
+			if u, err := rebaseURL(c.ImageURL, cfg.ImageBaseURL); err != nil {
+				slog.With("cardnumber", c.CardNumber).Error(fmt.Sprintf("Couldn't rebase image URL: %v", err))
+			} else {
+				c.ImageURL = u
+			}
+		}
+
+		if s.task != nil && s.task.seen != nil && c.CardNumber != "" {
+			if prevURL, dup := s.task.seen.mark(c.CardNumber, s.url); dup {
+				slog.With("cardnumber", c.CardNumber).Warn("Duplicate card in scrape", "firstURL", prevURL, "url", s.url)
+				if cfg.DropDuplicates {
+					wgCardSel.Done()
+					continue
+				}
+			}
+		}
+
+		if cfg.ComputeSearchName {
+			c.SearchName = searchName(c.Name)
+		}
+		if cfg.ExtractKeywords {
+			c.Keywords = cardKeywords(c.Text)
+		}
+		if cfg.ParseCosts {
+			c.AbilityCosts = abilityCosts(c.Text)
+		}
+		if cfg.ForceCardVersion != "" {
+			c.Version = cfg.ForceCardVersion
+		}
+
+		if !cfg.keepCard(c) {
+			slog.With("cardnumber", c.CardNumber).Debug("Card filtered out")
+			wgCardSel.Done()
+			continue
+		}
 
-		if getImages {
-			if img, err := getImage(c.ImageURL); err != nil {
+		if cfg.GetImages && c.ImageURL != "" {
+			if img, err := getImage(c.ImageURL, cfg.imageInterval(), cfg.imageClient); err != nil {
 				slog.Error(fmt.Sprintf("Problem getting image for %s: %v", c.CardNumber, err))
 			} else {
 				c.Image = img
+				c.ImageWidth, c.ImageHeight = img.Bounds().Dx(), img.Bounds().Dy()
 			}
 		}
 
 		cardCh <- c
+		if s.task != nil {
+			if !isTrullyNotFoil(c) {
+				atomic.AddInt64(&s.task.parallels, 1)
+			}
+			s.task.progress.update(func(p *Progress) { p.Cards++ })
+		}
 		wgCardSel.Done()
 	}
 }
@@ -485,11 +1648,24 @@ func (clr *cardListReducer) reduce(rc reducerConfig) {
 
 type boosterReducer struct {
 	boosterMap map[string]Booster
+	// dedupe drops cards already in their booster.
+	dedupe bool
 }
 
 func (br *boosterReducer) reduce(rc reducerConfig) {
+	br.boosterMap = make(map[string]Booster)
+	seen := make(map[string]*seenCards)
 	for c := range rc.cardCh {
 		boosterCode := c.Release
+		if br.dedupe {
+			if seen[boosterCode] == nil {
+				seen[boosterCode] = newSeenCards()
+			}
+			if _, dup := seen[boosterCode].mark(c.CardNumber, ""); dup {
+				slog.With("cardnumber", c.CardNumber).Debug("Dropping duplicate card from booster", "booster", boosterCode)
+				continue
+			}
+		}
 		boosterObj := br.boosterMap[boosterCode]
 		boosterObj.ReleaseCode = boosterCode
 
@@ -499,57 +1675,321 @@ func (br *boosterReducer) reduce(rc reducerConfig) {
 	rc.wg.Done()
 }
 
+type setReducer struct {
+	sets map[string][]Card
+}
+
+func (sr *setReducer) reduce(rc reducerConfig) {
+	sr.sets = make(map[string][]Card)
+	for c := range rc.cardCh {
+		sr.sets[c.SetID] = append(sr.sets[c.SetID], c)
+	}
+	for _, cards := range sr.sets {
+		sort.Slice(cards, func(i, j int) bool {
+			return cards[i].CardNumber < cards[j].CardNumber
+		})
+	}
+	rc.wg.Done()
+}
+
+// UntaggedTrait is the group of cards without any trait in Traits.
+const UntaggedTrait = "untagged"
+
+// traitReducer groups cards by their first trait.
+type traitReducer struct {
+	traits map[string][]Card
+}
+
+func (tr *traitReducer) reduce(rc reducerConfig) {
+	tr.traits = make(map[string][]Card)
+	for c := range rc.cardCh {
+		trait := UntaggedTrait
+		if len(c.Traits) > 0 && c.Traits[0] != "" {
+			trait = c.Traits[0]
+		}
+		tr.traits[trait] = append(tr.traits[trait], c)
+	}
+	for _, cards := range tr.traits {
+		sort.Slice(cards, func(i, j int) bool {
+			return cards[i].CardNumber < cards[j].CardNumber
+		})
+	}
+	rc.wg.Done()
+}
+
+type imageURLReducer struct {
+	urls []string
+}
+
+func (ir *imageURLReducer) reduce(rc reducerConfig) {
+	for c := range rc.cardCh {
+		if c.ImageURL != "" {
+			ir.urls = append(ir.urls, c.ImageURL)
+		}
+	}
+	rc.wg.Done()
+}
+
+type cardNumberReducer struct {
+	numbers []string
+}
+
+func (cr *cardNumberReducer) reduce(rc reducerConfig) {
+	for c := range rc.cardCh {
+		if c.CardNumber != "" {
+			cr.numbers = append(cr.numbers, c.CardNumber)
+		}
+	}
+	sort.Strings(cr.numbers)
+	rc.wg.Done()
+}
+
 func prepareBiri(cfg siteConfig) {
 	biri.Config.PingServer = cfg.baseURL
 	biri.Config.TickMinuteDuration = 1
 	biri.Config.Timeout = 25
 }
 
+// waitForProxy blocks until biri has at least one usable proxy or the timeout
+// expires. A timeout of 0 disables the check and keeps the old behaviour of
+// waiting forever.
+//
+// biri.GetClient can't be cancelled, so after a timeout the goroutine waiting
+// on it stays blocked until biri finds a proxy, which it then puts back. The
+// run is expected to give up on the error, usually by exiting the process.
+func waitForProxy(timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	slog.Debug(fmt.Sprintf("Waiting up to %v for a proxy", timeout))
+	proxyCh := make(chan *biri.Proxy)
+	timedOut := make(chan struct{})
+	go func() {
+		proxy := biri.GetClient()
+		select {
+		case proxyCh <- proxy:
+		case <-timedOut:
+			// Nobody is waiting for it anymore.
+			proxy.Readd()
+		}
+	}()
+	select {
+	case proxy := <-proxyCh:
+		// Put it back so the workers can use it.
+		proxy.Readd()
+		return nil
+	case <-time.After(timeout):
+		close(timedOut)
+		return fmt.Errorf("no usable proxy available after %v", timeout)
+	}
+}
+
+// Keyword modes for Config.KeywordMode.
+const (
+	KeywordOr  = "or"
+	KeywordAnd = "and"
+)
+
+// CardColors are the values Card.Color can take. PURPLE is only used by two
+// cards (PY/S38-120 and PY/S38-125).
+var CardColors = []string{"BLUE", "GREEN", "RED", "YELLOW", "PURPLE"}
+
+// CardTypes are the values Card.Type can take.
+var CardTypes = []string{"CH", "EV", "CX"}
+
 type Config struct {
+	// Colors only keeps cards of these colors, see CardColors. Empty keeps all
+	// colors.
+	Colors []string
+	// ComputeSearchName fills Card.SearchName.
+	ComputeSearchName bool
 	// The website's internal code for each expansion. The value is language-specific.
 	// For example,
 	//   159 is "BanG Dream! Girls Band Party Premium Booster" in EN
 	//   159 is "Monogatari Series: Second Season"
 	ExpansionNumber int
-	GetAllRarities  bool
-	GetImages       bool
-	GetRecent       bool
-	Language        SiteLanguage
-	PageStart       int
-	Reverse         bool
-	SetCode         []string
+	// DirectConnection makes ExpansionList and DownloadImage skip the proxies.
+	DirectConnection bool
+	// DropDuplicates skips cards whose number was already extracted by the
+	// same scrape task instead of only warning about them. Boosters also drop
+	// cards already in the same release.
+	DropDuplicates bool
+	GetAllRarities bool
+	GetImages      bool
+	GetRecent      bool
+	// HTTPClient, if set, is used for every request instead of the proxies,
+	// e.g. for custom transports or tests. It gets a cookie jar if it has
+	// none. Nil uses the proxies.
+	HTTPClient *http.Client `json:"-"`
+	// ImageRequestInterval is the minimum time between image downloads. The
+	// images come from a CDN so this can be lower than the interval used for
+	// the card pages. 0 uses the same interval as the card pages.
+	ImageRequestInterval time.Duration
+	// ExtractKeywords fills Card.Keywords.
+	ExtractKeywords bool
+	// ForceCardVersion overrides CardModelVersion on every card. Only meant
+	// for generating old-version fixtures to test migrations.
+	ForceCardVersion string
+	// IncludeReleaseDate fills Card.ReleaseDate from the products pages. This
+	// needs extra requests and is only supported on the JP site.
+	IncludeReleaseDate bool
+	// ImageBaseURL replaces the scheme and host of every card's ImageURL,
+	// e.g. to point at a mirror of the card images.
+	ImageBaseURL string
+	// KeywordMode is how the EN site matches SetCode keywords: KeywordOr (the
+	// default) matches any of them, KeywordAnd requires all of them.
+	KeywordMode string
+	Language    SiteLanguage
+	PageStart   int
+	// MaxDuration stops fetching new pages once the run has taken that long.
+	// Pages already being fetched are finished and CardsStream returns the
+	// cards so far with an error wrapping context.DeadlineExceeded. 0 means no
+	// limit.
+	MaxDuration time.Duration
+	// MaxConsecutiveFailures aborts the run with an error once that many pages
+	// failed all their fetch retries in a row, counting every worker. 0 never
+	// aborts.
+	MaxConsecutiveFailures int
+	// OnProgress, if set, is called every time a page is scanned or a card is
+	// sent. Calls are never concurrent but come from the worker goroutines,
+	// so it should return quickly.
+	OnProgress func(Progress) `json:"-"`
+	// PanicOnExtractError re-panics when extracting a card panics instead of
+	// logging it and keeping the partial card. Meant for debugging parsers.
+	PanicOnExtractError bool
+	// ParseCosts fills Card.AbilityCosts.
+	ParseCosts bool
+	// ProxyWaitTimeout is how long to wait for biri to find a usable proxy
+	// before giving up. 0 waits forever. A goroutine stays blocked on biri
+	// after a timeout, so the caller should give up rather than try again.
+	ProxyWaitTimeout time.Duration
+	// RespectRobotsTxt fetches the site's robots.txt once and skips the pages
+	// it disallows. The run fails if it disallows the search itself.
+	RespectRobotsTxt bool
+	// RetryTargets are card numbers or detail page URLs to fetch instead of
+	// running the normal search, e.g. the cards that failed in a previous run.
+	RetryTargets []string
+	Reverse      bool
+	SetCode      []string
+	// Side only keeps cards of that side, "W" or "S". Empty keeps both.
+	// The recent releases page doesn't say which side an expansion is, so
+	// this filters the extracted cards rather than the expansions.
+	Side string
+	// SkipCardNumber, if set, drops the cards it returns true for, e.g. the
+	// ones already downloaded. EN cards are dropped before their detail page
+	// is fetched.
+	SkipCardNumber func(cardNumber string) bool `json:"-"`
+	// SkipExpansion, if set, is called before scraping each search of a
+	// single expansion (e.g. the GetRecent ones) with the number of cards the
+	// site says it has, or 0 if the site doesn't say. Returning true skips
+	// it, e.g. because its cards were already downloaded.
+	SkipExpansion func(expansion, expected int) bool `json:"-"`
+	// SplitSetCodes runs one search per SetCode, each with its own pagination
+	// and stats, instead of a single search matching any of them.
+	SplitSetCodes bool
 	// The website's internal code for each set. The value is language-specific.
 	// For example
 	//   159 is "Tokyo Revengers" in EN
 	//   159 isn't supported in JP
 	TitleNumber int
+	// TriggerLabels replaces the trigger names written in the ability text,
+	// e.g. {"SOUL": "S"} turns [SOUL] into [S]. Triggers without a label keep
+	// their name. LabelTriggers uses the labels, upper-cased, in Card.Triggers
+	// too.
+	TriggerLabels map[string]string
+	LabelTriggers bool
+	// Types only keeps cards of these types, see CardTypes. Empty keeps all
+	// types. With a single type the JP search is filtered too, so fewer pages
+	// are fetched.
+	Types []string
+	// UseGET sends the searches as GET requests with the values in the query
+	// instead of posting them like the site's form, so caching proxies can
+	// cache the result pages. The sites aren't known to honour every value
+	// that way, so check the results first, e.g. with CheckSite.
+	UseGET bool
+	// UseSitemap fetches the detail pages listed in the site's sitemap
+	// instead of paginating through the search results. Only Side, Colors,
+	// Types and SkipCardNumber apply to it, the other filters are errors. It
+	// falls back to the search if the site has no sitemap or no detail pages.
+	// Ignored with RetryTargets.
+	UseSitemap bool
+	// WorkerStartJitter is the spread of the random delays the page fetch
+	// workers wait before their first request. 0 uses the default of 100ms
+	// and a negative value starts them all at once.
+	WorkerStartJitter time.Duration
+	// RandSeed seeds the random jitter of the run so it's reproducible. 0
+	// uses a random seed.
+	RandSeed int64
+	// Workers is the number of workers talking to the website. Local workers
+	// are scaled to twice that. 0 uses the defaults.
+	Workers int
 }
 
-func CardsStream(cfg Config, cardCh chan<- Card) error {
-	var siteCfg siteConfig
-	if c, ok := siteConfigs[cfg.Language]; !ok {
-		return fmt.Errorf("unsupported language: %v", cfg.Language)
-	} else {
-		siteCfg = c
-		slog.Info(fmt.Sprintf("Fetching %v cards", cfg.Language))
+// keepCard reports whether card passes the filters in c.
+func (c Config) keepCard(card Card) bool {
+	if c.Side != "" && card.Side != c.Side {
+		return false
 	}
+	if len(c.Colors) > 0 && !slices.Contains(c.Colors, card.Color) {
+		return false
+	}
+	if len(c.Types) > 0 && !slices.Contains(c.Types, card.Type) {
+		return false
+	}
+	if c.SkipCardNumber != nil && c.SkipCardNumber(card.CardNumber) {
+		return false
+	}
+	return true
+}
 
-	slog.Info("Streaming cards", "config", cfg)
+func (c Config) imageInterval() time.Duration {
+	if c.ImageRequestInterval > 0 {
+		return c.ImageRequestInterval
+	}
+	return minTimeBetweenRequests
+}
 
-	prepareBiri(siteCfg)
-	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	if err != nil {
-		return fmt.Errorf("failed to get new cookiejar: %v", err)
+// startDelay returns a random delay within the worker start jitter.
+func (c Config) startDelay(rng *lockedRand) time.Duration {
+	jitter := c.WorkerStartJitter
+	if jitter == 0 {
+		jitter = defaultWorkerStartJitter
+	}
+	if jitter < 0 {
+		return 0
+	}
+	return time.Duration(rng.Int63n(int64(jitter)))
+}
+
+func (c Config) scrapeWorkers() int {
+	if c.Workers > 0 {
+		return c.Workers
+	}
+	return maxScrapeWorker
+}
+
+func (c Config) localWorkers() int {
+	if c.Workers > 0 {
+		return 2 * c.Workers
 	}
+	return maxLocalWorker
+}
 
-	biri.ProxyStart()
+// enResultCount reads the number of results of an EN search page.
+func enResultCount(doc *goquery.Document) (int, error) {
+	return strconv.Atoi(doc.Find(".c-search__results-item span").First().Text())
+}
 
+// searchValues builds the search form values for cfg.
+func searchValues(cfg Config, siteCfg siteConfig) (url.Values, error) {
 	urlValues := siteCfg.baseURLValues()
 	if cfg.ExpansionNumber != 0 {
 		switch cfg.Language {
 		case English:
-			// "expansion" also works, but the website uses "expansion_name", so use "expansion" to
-			// stay in line with the website
+			// The website's form sends "expansion_name" but "expansion" works
+			// too. Send both so the filter still applies if the site stops
+			// honouring one of them.
+			urlValues.Add("expansion", strconv.Itoa(cfg.ExpansionNumber))
 			urlValues.Add("expansion_name", strconv.Itoa(cfg.ExpansionNumber))
 		case Japanese:
 			urlValues.Add("expansion", strconv.Itoa(cfg.ExpansionNumber))
@@ -557,10 +1997,14 @@ func CardsStream(cfg Config, cardCh chan<- Card) error {
 	}
 	if cfg.TitleNumber != 0 {
 		if !siteCfg.supportTitleNumber {
-			return fmt.Errorf("can't use title number on %v site", cfg.Language)
+			return nil, fmt.Errorf("can't use title number on %v site", cfg.Language)
 		}
 		urlValues.Add("title", strconv.Itoa(cfg.TitleNumber))
 	}
+	if len(cfg.Types) == 1 && siteCfg.typeValues != nil {
+		// The site only takes one type, more are left to keepCard.
+		urlValues.Add("card_kind", siteCfg.typeValues[cfg.Types[0]])
+	}
 	if cfg.GetAllRarities {
 		urlValues.Add("parallel", "0")
 	} else {
@@ -569,28 +2013,196 @@ func CardsStream(cfg Config, cardCh chan<- Card) error {
 	if len(cfg.SetCode) > 0 {
 		switch cfg.Language {
 		case English:
-			urlValues.Add("keyword_or", strings.Join(cfg.SetCode, " "))
+			switch cfg.KeywordMode {
+			case "", KeywordOr:
+				urlValues.Add("keyword_or", strings.Join(cfg.SetCode, " "))
+			case KeywordAnd:
+				urlValues.Add("keyword_and", strings.Join(cfg.SetCode, " "))
+			default:
+				return nil, fmt.Errorf("unsupported keyword mode: %q", cfg.KeywordMode)
+			}
 			urlValues.Add("keyword_type[]", "no")
 		case Japanese:
 			urlValues.Add("title_number", fmt.Sprintf("##%s##", strings.Join(cfg.SetCode, "##")))
 		}
 	}
+	return urlValues, nil
+}
+
+// getTasksForSetCodes makes one search task per set code in cfg.SetCode.
+func getTasksForSetCodes(cfg Config, siteCfg siteConfig) ([]scrapeTask, error) {
+	var tasks []scrapeTask
+	for _, setCode := range cfg.SetCode {
+		setCfg := cfg
+		setCfg.SetCode = []string{setCode}
+		urlValues, err := searchValues(setCfg, siteCfg)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, scrapeTask{urlValues: urlValues})
+	}
+	return tasks, nil
+}
+
+// CardsStream sends every card matching cfg to cardCh and closes it when done.
+func CardsStream(cfg Config, cardCh chan<- Card) error {
+	_, err := CardsStreamWithStats(cfg, cardCh)
+	return err
+}
+
+// CardsStreamWithStats is CardsStream but also returns stats about each search
+// it ran.
+func CardsStreamWithStats(cfg Config, cardCh chan<- Card) (ScrapeStats, error) {
+	var stats ScrapeStats
+	// Always close the channel so consumers ranging over it don't hang when we
+	// bail out early.
+	defer close(cardCh)
+
+	errs := &scrapeErrors{}
+	var siteCfg siteConfig
+	if c, ok := siteConfigs[cfg.Language]; !ok {
+		return stats, fmt.Errorf("unsupported language: %v", cfg.Language)
+	} else {
+		siteCfg = c
+		siteCfg.panicOnExtractError = cfg.PanicOnExtractError
+		siteCfg.errors = errs
+		siteCfg.applyTriggerLabels(cfg)
+		slog.Info(fmt.Sprintf("Fetching %v cards", cfg.Language))
+	}
+
+	slog.Info("Streaming cards", "config", cfg)
+	if cfg.ForceCardVersion != "" && cfg.ForceCardVersion != CardModelVersion {
+		slog.Warn(fmt.Sprintf("!!! Stamping cards with non-standard version %q instead of %q. Don't use this output as real data !!!", cfg.ForceCardVersion, CardModelVersion))
+	}
+
+	switch cfg.Side {
+	case "", "W", "S":
+	default:
+		return stats, fmt.Errorf("unsupported side: %q", cfg.Side)
+	}
+	for _, color := range cfg.Colors {
+		if !slices.Contains(CardColors, color) {
+			return stats, fmt.Errorf("unsupported color: %q, expected one of %v", color, CardColors)
+		}
+	}
+	for _, cardType := range cfg.Types {
+		if !slices.Contains(CardTypes, cardType) {
+			return stats, fmt.Errorf("unsupported type: %q, expected one of %v", cardType, CardTypes)
+		}
+	}
+	if cfg.UseSitemap && (cfg.ExpansionNumber != 0 || cfg.TitleNumber != 0 || len(cfg.SetCode) > 0 || cfg.GetRecent) {
+		// The sitemap lists every card and the search filters can only be
+		// checked once the card is fetched.
+		return stats, fmt.Errorf("the sitemap can't be combined with an expansion, title, set code or recent filter")
+	}
+
+	urlValues, err := searchValues(cfg, siteCfg)
+	if err != nil {
+		return stats, err
+	}
+
+	prepareBiri(siteCfg)
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return stats, fmt.Errorf("failed to get new cookiejar: %v", err)
+	}
+
+	httpClient := cfg.httpClient(jar)
+	getDoc := getDocument
+	directClient := &http.Client{Timeout: lastPageTimeout}
+	if httpClient != nil {
+		getDoc = clientDocument(httpClient)
+		directClient = httpClient
+	} else {
+		biri.ProxyStart()
+		if err := waitForProxy(cfg.ProxyWaitTimeout); err != nil {
+			biri.Done()
+			return stats, err
+		}
+		// Stop biri's background goroutines however we return.
+		defer biri.Done()
+		proxiesRunning.Add(1)
+		defer proxiesRunning.Add(-1)
+	}
+
+	var dates *releaseDateIndex
+	if cfg.IncludeReleaseDate {
+		if cfg.Language == Japanese {
+			// The products pages are only read as cards need them.
+			dates = newReleaseDateIndex(getDoc)
+		} else {
+			slog.Warn(fmt.Sprintf("Release dates aren't available on the %v site", cfg.Language))
+		}
+	}
 
 	var scrapeTasks []*scrapeTask
+	progress := newProgressTracker(cfg.OnProgress)
+	rng := newLockedRand(cfg.RandSeed)
+	var deadline time.Time
+	if cfg.MaxDuration > 0 {
+		deadline = time.Now().Add(cfg.MaxDuration)
+	}
+	var robots *robotsRules
+	if cfg.RespectRobotsTxt {
+		if robots, err = fetchRobots(directClient, siteCfg.baseURL); err != nil {
+			return stats, err
+		}
+	}
+
 	defaultScrapeTask := scrapeTask{
 		cookieJar:  jar,
 		siteConfig: siteCfg,
 		urlValues:  urlValues,
+		progress:   progress,
+		rng:        rng,
+		deadline:   deadline,
+		robots:     robots,
+		failures:   newFailureTracker(cfg.MaxConsecutiveFailures),
+		errors:     errs,
+		httpClient: httpClient,
+		catalog:    &catalogSizes{},
+
+		skipCardNumber: cfg.SkipCardNumber,
+		pageStart:      cfg.PageStart,
+		useGET:         cfg.UseGET,
+	}
+	if len(cfg.RetryTargets) == 0 && cfg.UseSitemap {
+		if siteCfg.cardDetailURL == "" {
+			slog.Warn(fmt.Sprintf("Falling back to the search: the %v site's detail pages can't be extracted", cfg.Language))
+		} else if urls, err := sitemapCardURLs(directClient, siteCfg.baseURL+"sitemap.xml", 0); err != nil {
+			slog.Warn(fmt.Sprintf("Falling back to the search: %v", err))
+		} else if len(urls) == 0 {
+			slog.Warn("Falling back to the search: no cards in the sitemap")
+		} else {
+			slog.Info(fmt.Sprintf("Found %d cards in the sitemap", len(urls)))
+			return streamDetailPages(cfg, &defaultScrapeTask, dates, urls, cardCh)
+		}
 	}
-	if cfg.GetRecent {
-		resp, err := http.Get(siteCfg.cardListURL)
+	if len(cfg.RetryTargets) > 0 {
+		for _, retry := range getTasksForRetryTargets(siteCfg, cfg.RetryTargets) {
+			copyTask := defaultScrapeTask
+			copyTask.urlValues = retry.urlValues
+			scrapeTasks = append(scrapeTasks, &copyTask)
+		}
+	} else if cfg.SplitSetCodes && len(cfg.SetCode) > 1 {
+		setTasks, err := getTasksForSetCodes(cfg, siteCfg)
+		if err != nil {
+			return stats, err
+		}
+		for _, set := range setTasks {
+			copyTask := defaultScrapeTask
+			copyTask.urlValues = set.urlValues
+			scrapeTasks = append(scrapeTasks, &copyTask)
+		}
+	} else if cfg.GetRecent {
+		resp, err := directClient.Get(siteCfg.cardListURL)
 		if err != nil {
-			return fmt.Errorf("error getting recent: %v", err)
+			return stats, fmt.Errorf("error getting recent: %v", err)
 		}
 		defer resp.Body.Close()
 		doc, err := goquery.NewDocumentFromReader(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error parsing recent: %v", err)
+			return stats, fmt.Errorf("error parsing recent: %v", err)
 		}
 		for _, recent := range getTasksForRecentReleases(siteCfg, doc) {
 			copyTask := defaultScrapeTask
@@ -603,37 +2215,55 @@ func CardsStream(cfg Config, cardCh chan<- Card) error {
 	}
 
 	loopNum := 0
+	totalPages := 0
 	for _, st := range scrapeTasks {
 		lastPage, err := st.getLastPage()
 		if err != nil {
-			return err
+			return stats, err
+		}
+		if expansion, ok := st.expansion(); ok && cfg.SkipExpansion != nil && cfg.SkipExpansion(expansion, st.resultCount) {
+			slog.Info(fmt.Sprintf("Skipping expansion %d", expansion))
+			lastPage = 0
+			st.lastPage = 0
 		}
 		loopNum += lastPage
+		totalPages += lastPage - min(max(cfg.PageStart-1, 0), lastPage)
 		st.pageURLCh = make(chan string, lastPage)
-		st.pageRespCh = make(chan *http.Response, maxScrapeWorker)
+		st.pageRespCh = make(chan *http.Response, cfg.scrapeWorkers())
 		st.wgPageScan = &sync.WaitGroup{}
 		st.wgPageScan.Add(lastPage)
+		st.seen = newSeenCards()
+		st.removed = &removedCards{}
+		st.requeues = &pageRequeues{}
 	}
 
 	slog.Debug(fmt.Sprintf("Number of loop %v", loopNum))
+	progress.update(func(p *Progress) { p.TotalPages = totalPages })
 
 	var wgScanner, wgCardSel sync.WaitGroup
-	cardSelCh := make(chan *goquery.Selection, maxLocalWorker)
-	for i := 0; i < maxLocalWorker; i++ {
-		go extractWorker(siteCfg, cfg.GetImages, &wgCardSel, cardSelCh, cardCh)
+	cardSelCh := make(chan cardSelection, cfg.localWorkers())
+	for i := 0; i < cfg.localWorkers(); i++ {
+		go extractWorker(siteCfg, cfg, dates, &wgCardSel, cardSelCh, cardCh)
 	}
 	for _, st := range scrapeTasks {
 		wgScanner.Add(1)
+		st.start = time.Now()
 		go func(s *scrapeTask) {
 			// Wait for page scanning to finish instead of the fetch workers because
 			// sometimes the scanners put work back in the fetch channel.
 			s.wgPageScan.Wait()
+			s.end = time.Now()
 			close(s.pageURLCh)
 			close(s.pageRespCh)
+			ts := s.stats()
+			slog.Info("Scrape task done", "expansion", ts.Expansion, "cards", ts.CardsFound, "pages", ts.PagesScanned, "removed", len(ts.Removed), "duration", ts.Duration)
+			if ts.shortfall() {
+				slog.Warn(fmt.Sprintf("%d of the %d expected cards are missing, detail pages were probably dropped", ts.MissingCards, ts.ExpectedCards), "expansion", ts.Expansion, "query", ts.Query)
+			}
 			wgScanner.Done()
 		}(st)
-		for i := 0; i < maxScrapeWorker; i++ {
-			go pageFetchWorker(i, st)
+		for i := 0; i < cfg.scrapeWorkers(); i++ {
+			go pageFetchWorker(i, st, cfg.startDelay(rng))
 			go pageScanWorker(i, st, &wgCardSel, cardSelCh)
 		}
 		for i := 1; i <= st.lastPage; i++ {
@@ -654,14 +2284,90 @@ func CardsStream(cfg Config, cardCh chan<- Card) error {
 	wgScanner.Wait()
 	wgCardSel.Wait()
 	close(cardSelCh)
-	close(cardCh)
-	biri.Done()
 
-	return nil
+	skipped := 0
+	for _, st := range scrapeTasks {
+		stats.Tasks = append(stats.Tasks, st.stats())
+		skipped += int(atomic.LoadInt64(&st.pagesSkipped))
+	}
+	stats.Errors = errs.list()
+	if defaultScrapeTask.failures.aborted() {
+		return stats, fmt.Errorf("aborted after %d pages failed in a row", cfg.MaxConsecutiveFailures)
+	}
+	if skipped > 0 {
+		return stats, fmt.Errorf("stopped after %v with %d pages left: %w", cfg.MaxDuration, skipped, context.DeadlineExceeded)
+	}
+	return stats, nil
+}
+
+// ScrapeSinglePage returns the cards on one page of the search matching cfg,
+// without the proxies or the workers, to reproduce extraction problems. It
+// connects directly unless cfg.HTTPClient is set and never fetches images.
+func ScrapeSinglePage(cfg Config, page int) ([]Card, error) {
+	siteCfg, ok := siteConfigs[cfg.Language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %v", cfg.Language)
+	}
+	siteCfg.panicOnExtractError = cfg.PanicOnExtractError
+	siteCfg.applyTriggerLabels(cfg)
+	urlValues, err := searchValues(cfg, siteCfg)
+	if err != nil {
+		return nil, err
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new cookiejar: %v", err)
+	}
+	client := cfg.httpClient(jar)
+	if client == nil {
+		client = &http.Client{Timeout: lastPageTimeout, Jar: jar}
+	}
+
+	link := fmt.Sprintf("%v?page=%d", siteCfg.cardSearchURL, page)
+	resp, err := search(client, link, urlValues, cfg.UseGET)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get page %d: %v", page, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't get page %d: bad status code=%d", page, resp.StatusCode)
+	}
+
+	task := &scrapeTask{
+		// The parse funcs put the page back when they fail to read it.
+		pageURLCh:  make(chan string, 1),
+		siteConfig: siteCfg,
+		urlValues:  urlValues,
+		cookieJar:  jar,
+		seen:       newSeenCards(),
+		httpClient: client,
+	}
+	cfg.GetImages = false
+	var wgCardSel sync.WaitGroup
+	cardSelCh := make(chan cardSelection)
+	cardCh := make(chan Card)
+	go func() {
+		extractWorker(siteCfg, cfg, nil, &wgCardSel, cardSelCh, cardCh)
+		close(cardCh)
+	}()
+	var parsed bool
+	go func() {
+		parsed = siteCfg.pageScanParseFunc(task, &wgCardSel, cardSelCh, resp)
+		close(cardSelCh)
+	}()
+
+	var cards []Card
+	for c := range cardCh {
+		cards = append(cards, c)
+	}
+	if !parsed {
+		return cards, fmt.Errorf("couldn't parse page %d", page)
+	}
+	return cards, nil
 }
 
 func aggregate(cfg Config, r reducer) error {
-	cardCh := make(chan Card, maxScrapeWorker)
+	cardCh := make(chan Card, cfg.scrapeWorkers())
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -688,24 +2394,150 @@ func Cards(cfg Config) ([]Card, error) {
 }
 
 func Boosters(cfg Config) (map[string]Booster, error) {
-	var reducer boosterReducer
+	reducer := boosterReducer{dedupe: cfg.DropDuplicates}
 	err := aggregate(cfg, &reducer)
 
 	return reducer.boosterMap, err
 }
 
+// Sets returns the cards matching cfg grouped by SetID, each group sorted by
+// card number.
+func Sets(cfg Config) (map[string][]Card, error) {
+	var reducer setReducer
+	err := aggregate(cfg, &reducer)
+
+	return reducer.sets, err
+}
+
+// CardNumbers returns the sorted card numbers of every card matching cfg.
+// Images aren't downloaded.
+func CardNumbers(cfg Config) ([]string, error) {
+	cfg.GetImages = false
+	var reducer cardNumberReducer
+	err := aggregate(cfg, &reducer)
+
+	return reducer.numbers, err
+}
+
+// Traits returns the cards matching cfg grouped by their first trait, each
+// group sorted by card number. Cards without traits are under UntaggedTrait.
+func Traits(cfg Config) (map[string][]Card, error) {
+	var reducer traitReducer
+	err := aggregate(cfg, &reducer)
+
+	return reducer.traits, err
+}
+
+// ImageURLs returns the image URL of every card matching cfg. Images aren't
+// downloaded.
+func ImageURLs(cfg Config) ([]string, error) {
+	cfg.GetImages = false
+	var reducer imageURLReducer
+	err := aggregate(cfg, &reducer)
+
+	return reducer.urls, err
+}
+
+// cardPermalink returns the detail page URL of cardNumber. The number is
+// escaped so a + (e.g. SSP+) isn't read as a space, but / is kept as the site
+// writes it. Sites without an extractable detail page link to the card list's
+// own cardno page.
+func cardPermalink(siteCfg siteConfig, cardNumber string) string {
+	base := siteCfg.cardDetailURL
+	if base == "" {
+		base = siteCfg.cardListURL
+	}
+	escaped := strings.ReplaceAll(url.QueryEscape(cardNumber), "%2F", "/")
+	return fmt.Sprintf("%v?cardno=%v", base, escaped)
+}
+
+// CardByNumber fetches a single card by its number without proxies. On the EN
+// site it goes straight to the card's permalink. The JP permalink page has a
+// different layout, so it searches for the number and keeps the exact match.
+func CardByNumber(cardNumber string, lang SiteLanguage) (Card, error) {
+	siteCfg, ok := siteConfigs[lang]
+	if !ok {
+		return Card{}, fmt.Errorf("unsupported language: %v", lang)
+	}
+	cardNumber = sanitizeCardNumber(cardNumber)
+	client := &http.Client{Timeout: lastPageTimeout}
+
+	var resp *http.Response
+	var err error
+	if siteCfg.cardDetailURL != "" {
+		resp, err = client.Get(cardPermalink(siteCfg, cardNumber))
+	} else {
+		resp, err = client.PostForm(siteCfg.cardSearchURL, siteCfg.cardNumberValues(cardNumber))
+	}
+	if err != nil {
+		return Card{}, fmt.Errorf("couldn't get card %v: %v", cardNumber, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Card{}, fmt.Errorf("couldn't get card %v: bad status code=%d", cardNumber, resp.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return Card{}, fmt.Errorf("couldn't parse page for %v: %v", cardNumber, err)
+	}
+	return findCard(siteCfg, doc, cardNumber)
+}
+
+// findCard extracts the card numbered cardNumber from a detail or search
+// result page.
+func findCard(siteCfg siteConfig, doc *goquery.Document, cardNumber string) (Card, error) {
+	if siteCfg.cardDetailURL != "" {
+		details := doc.Find(siteCfg.detailSelector)
+		if details.Length() == 0 {
+			return Card{}, fmt.Errorf("no card details for %v", cardNumber)
+		}
+		card := extractData(siteCfg, details)
+		if card.CardNumber != cardNumber {
+			return Card{}, fmt.Errorf("got card %v instead of %v", card.CardNumber, cardNumber)
+		}
+		return card, nil
+	}
+
+	var found *Card
+	doc.Find(".search-result-table tr").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if card := extractData(siteCfg, s); card.CardNumber == cardNumber {
+			found = &card
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return Card{}, fmt.Errorf("card %v not found", cardNumber)
+	}
+	return *found, nil
+}
+
 // ExpansionList returns a map of expansion numbers to their titles for the
 // specified language in the Config.
 func ExpansionList(cfg Config) (map[int]string, error) {
+	return cardListOptions(cfg, "expansion")
+}
+
+// TitleList returns a map of title numbers to their names for the specified
+// language in the Config. Only the EN site has title numbers.
+func TitleList(cfg Config) (map[int]string, error) {
+	if c, ok := siteConfigs[cfg.Language]; ok && !c.supportTitleNumber {
+		return nil, fmt.Errorf("can't list titles on %v site", cfg.Language)
+	}
+	return cardListOptions(cfg, "title")
+}
+
+// cardListOptions returns the options of the select with the given id on the
+// card list page, keyed by their number.
+func cardListOptions(cfg Config, selectID string) (map[int]string, error) {
 	var siteCfg siteConfig
 	if c, ok := siteConfigs[cfg.Language]; !ok {
 		return nil, fmt.Errorf("unsupported language: %v", cfg.Language)
 	} else {
 		siteCfg = c
-		slog.Info(fmt.Sprintf("Fetching %v expansion list", cfg.Language))
+		slog.Info(fmt.Sprintf("Fetching %v %v list", cfg.Language, selectID))
 	}
 
-	prepareBiri(siteCfg)
 	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
 		err = fmt.Errorf("failed to get new cookiejar: %v", err)
@@ -713,13 +2545,28 @@ func ExpansionList(cfg Config) (map[int]string, error) {
 		return nil, err
 	}
 
-	biri.ProxyStart()
-
-	proxy := biri.GetClient()
-	slog.Debug("Got proxy")
-	proxy.Client.Jar = jar
+	// It's a single page, so don't bother with proxies unless asked to, and
+	// go direct if none turn up.
+	client := &http.Client{Timeout: lastPageTimeout, Jar: jar}
+	var proxy *biri.Proxy
+	if c := cfg.httpClient(jar); c != nil {
+		client = c
+	} else if !cfg.DirectConnection {
+		prepareBiri(siteCfg)
+		biri.ProxyStart()
+		if err := waitForProxy(cfg.ProxyWaitTimeout); err != nil {
+			biri.Done()
+			slog.Warn(fmt.Sprintf("Falling back to a direct connection: %v", err))
+		} else {
+			defer biri.Done()
+			proxy = biri.GetClient()
+			slog.Debug("Got proxy")
+			proxy.Client.Jar = jar
+			client = proxy.Client
+		}
+	}
 
-	resp, err := proxy.Client.PostForm(siteCfg.cardListURL, url.Values{})
+	resp, err := client.PostForm(siteCfg.cardListURL, url.Values{})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read page: %v", err)
 	}
@@ -727,20 +2574,26 @@ func ExpansionList(cfg Config) (map[int]string, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code: %v", resp.StatusCode)
 	}
-	proxy.Readd()
+	if proxy != nil {
+		proxy.Readd()
+	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("goquery error for page %q: %v", resp.Request.URL, err)
 	}
+	return parseSelectOptions(doc.Selection, selectID)
+}
 
-	expansionList := doc.Find("select#expansion option")
-	if expansionList.Length() == 0 && resp.StatusCode == http.StatusOK {
-		return nil, fmt.Errorf("couldn't find expansion list")
+// parseSelectOptions reads the numbered options of select#selectID.
+func parseSelectOptions(doc *goquery.Selection, selectID string) (map[int]string, error) {
+	options := doc.Find(fmt.Sprintf("select#%s option", selectID))
+	if options.Length() == 0 {
+		return nil, fmt.Errorf("couldn't find %v list", selectID)
 	}
 
-	eMap := make(map[int]string)
-	expansionList.Each(func(i int, s *goquery.Selection) {
+	optMap := make(map[int]string)
+	options.Each(func(i int, s *goquery.Selection) {
 		val, exists := s.Attr("value")
 		val = strings.TrimSpace(val)
 		if !exists || val == "" {
@@ -749,12 +2602,12 @@ func ExpansionList(cfg Config) (map[int]string, error) {
 			return
 		}
 		if v, err := strconv.Atoi(val); err != nil {
-			slog.Error(fmt.Sprintf("Error parsing expansion value: %v", err))
+			slog.Error(fmt.Sprintf("Error parsing %v value: %v", selectID, err))
 		} else {
-			eMap[v] = s.Text()
+			optMap[v] = s.Text()
 		}
 
 	})
 
-	return eMap, nil
+	return optMap, nil
 }
@@ -1,11 +1,30 @@
 package fetch
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"runtime"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/temoto/robotstxt"
+	"golang.org/x/text/language"
 )
 
 // func TestGetLastPage(t *testing.T) {
@@ -65,6 +84,8 @@ func TestRecentSwitch_jp(t *testing.T) {
 		"437",
 		"441",
 		"440",
+		"436",
+		"435",
 	}
 	f, err := os.Open("mockws/recent.html")
 	if err != nil {
@@ -77,8 +98,8 @@ func TestRecentSwitch_jp(t *testing.T) {
 		t.Fatal(err)
 	}
 	recentTasks := getTasksForRecentReleases(siteConfigs[Japanese], doc)
-	if len(recentTasks) != 8 {
-		t.Errorf("Should be equal to 8: %v", recentTasks)
+	if len(recentTasks) != len(expectedExpansion) {
+		t.Errorf("Should be equal to %d: %v", len(expectedExpansion), recentTasks)
 	}
 
 	for _, task := range recentTasks {
@@ -88,3 +109,1309 @@ func TestRecentSwitch_jp(t *testing.T) {
 		}
 	}
 }
+
+func TestSeenCards(t *testing.T) {
+	seen := newSeenCards()
+	if _, dup := seen.mark("BD/W63-036SPMa", "page1"); dup {
+		t.Error("First sighting shouldn't be a duplicate")
+	}
+	if _, dup := seen.mark("BD/W63-022", "page1"); dup {
+		t.Error("Different card shouldn't be a duplicate")
+	}
+	prev, dup := seen.mark("BD/W63-036SPMa", "page2")
+	if !dup {
+		t.Error("Second sighting should be a duplicate")
+	}
+	if prev != "page1" {
+		t.Errorf("Got first URL %q, want %q", prev, "page1")
+	}
+}
+
+func TestSearchValuesKeywordMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantKey string
+		wantErr bool
+	}{
+		{mode: "", wantKey: "keyword_or"},
+		{mode: KeywordOr, wantKey: "keyword_or"},
+		{mode: KeywordAnd, wantKey: "keyword_and"},
+		{mode: "xor", wantErr: true},
+	}
+	for _, tc := range tests {
+		cfg := Config{Language: English, SetCode: []string{"BD", "IM"}, KeywordMode: tc.mode}
+		v, err := searchValues(cfg, siteConfigs[English])
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("[%s]: expected an error", tc.mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%s]: unexpected error: %v", tc.mode, err)
+		}
+		if got := v.Get(tc.wantKey); got != "BD IM" {
+			t.Errorf("[%s]: got %s=%q, want %q", tc.mode, tc.wantKey, got, "BD IM")
+		}
+	}
+}
+
+func TestSearchValuesExpansion_en(t *testing.T) {
+	v, err := searchValues(Config{Language: English, ExpansionNumber: 159}, siteConfigs[English])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"expansion", "expansion_name"} {
+		if got := v.Get(key); got != "159" {
+			t.Errorf("got %s=%q, want %q", key, got, "159")
+		}
+	}
+}
+
+func TestGetLastPageChecksExpansionFilter(t *testing.T) {
+	var mu sync.Mutex
+	var expansions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		expansions = append(expansions, r.PostForm.Get("expansion"))
+		mu.Unlock()
+		fmt.Fprint(w, `<p class="c-search__results-item"><span>30</span> results</p>`)
+	}))
+	defer srv.Close()
+
+	siteCfg := siteConfigs[English]
+	siteCfg.cardSearchURL = srv.URL + "/cardlist/searchresults/"
+	urlValues, err := searchValues(Config{Language: English, ExpansionNumber: 159}, siteCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := &catalogSizes{}
+	task := scrapeTask{siteConfig: siteCfg, urlValues: urlValues, catalog: catalog}
+	last, err := task.getLastPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 2 {
+		t.Errorf("got last page %d, want 2", last)
+	}
+
+	// Another task of the run reuses the catalog size.
+	other := scrapeTask{siteConfig: siteCfg, urlValues: url.Values{}, catalog: catalog}
+	for k, v := range urlValues {
+		other.urlValues[k] = v
+	}
+	other.urlValues.Set("expansion", "160")
+	if _, err := other.getLastPage(); err != nil {
+		t.Fatal(err)
+	}
+	// The filtered search, then the whole catalog to compare counts.
+	if want := []string{"159", "", "160"}; !slices.Equal(expansions, want) {
+		t.Errorf("got requests with expansions %q, want %q", expansions, want)
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	cfg := Config{Language: Japanese, SetCode: []string{"W109"}, OnProgress: func(Progress) {}}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Language":"ja"`, `"SetCode":["W109"]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("got %s: expected it to contain %s", data, want)
+		}
+	}
+}
+
+func TestGetTasksForSetCodes(t *testing.T) {
+	cfg := Config{Language: Japanese, SetCode: []string{"W109", "S108"}}
+	tasks, err := getTasksForSetCodes(cfg, siteConfigs[Japanese])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"##W109##", "##S108##"}
+	if len(tasks) != len(want) {
+		t.Fatalf("Got %d tasks, want %d", len(tasks), len(want))
+	}
+	for i, task := range tasks {
+		if got := task.urlValues.Get("title_number"); got != want[i] {
+			t.Errorf("Got title_number %q, want %q", got, want[i])
+		}
+	}
+
+	cfg = Config{Language: English, SetCode: []string{"BD", "IM"}, KeywordMode: "xor"}
+	if _, err := getTasksForSetCodes(cfg, siteConfigs[English]); err == nil {
+		t.Error("expected an error for an unsupported keyword mode")
+	}
+}
+
+func TestGetTasksForRetryTargets(t *testing.T) {
+	targets := []string{
+		"BD/W63-036SPMa",
+		"https://en.ws-tcg.com/cardlist/list/?cardno=SS/WE41-E17",
+		" ",
+	}
+	tasks := getTasksForRetryTargets(siteConfigs[English], targets)
+	if len(tasks) != 2 {
+		t.Fatalf("Got %d tasks, want 2", len(tasks))
+	}
+	want := []string{"BD/W63-036SPMa", "SS/WE41-E17"}
+	for i, task := range tasks {
+		if got := task.urlValues.Get("keyword_or"); got != want[i] {
+			t.Errorf("Got keyword %q, want %q", got, want[i])
+		}
+	}
+}
+
+func TestRebaseURL(t *testing.T) {
+	tests := []struct {
+		raw, base, want string
+	}{
+		{
+			"https://en.ws-tcg.com/wp/wp-content/images/cardimages/SS/WE41_E17.png",
+			"https://cdn.example.com",
+			"https://cdn.example.com/wp/wp-content/images/cardimages/SS/WE41_E17.png",
+		},
+		{
+			"https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png?v=1",
+			"http://mirror.example.com/ws/",
+			"http://mirror.example.com/ws/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png?v=1",
+		},
+	}
+	for _, tc := range tests {
+		got, err := rebaseURL(tc.raw, tc.base)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestKeepCardSide(t *testing.T) {
+	weiss := Card{CardNumber: "BD/W63-022", Side: "W"}
+	schwarz := Card{CardNumber: "PY/S38-120", Side: "S"}
+
+	if cfg := (Config{}); !cfg.keepCard(weiss) || !cfg.keepCard(schwarz) {
+		t.Error("No side should keep every card")
+	}
+	cfg := Config{Side: "W"}
+	if !cfg.keepCard(weiss) {
+		t.Error("Weiss card should be kept")
+	}
+	if cfg.keepCard(schwarz) {
+		t.Error("Schwarz card should be dropped")
+	}
+}
+
+// assertNoGoroutineLeak fails the test if f leaves goroutines running.
+func assertNoGoroutineLeak(t *testing.T, f func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	f()
+	// Give exiting goroutines a moment to finish.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Leaked %d goroutines", after-before)
+	}
+}
+
+func TestCardsStreamNoLeakOnError(t *testing.T) {
+	tests := map[string]Config{
+		"unsupported language": {Language: SiteLanguage(language.French)},
+		"unsupported side":     {Language: English, Side: "X"},
+		"bad keyword mode":     {Language: English, SetCode: []string{"BD"}, KeywordMode: "xor"},
+		"title on JP":          {Language: Japanese, TitleNumber: 159},
+	}
+	for name, cfg := range tests {
+		assertNoGoroutineLeak(t, func() {
+			cardCh := make(chan Card)
+			if err := CardsStream(cfg, cardCh); err == nil {
+				t.Errorf("[%s]: expected an error", name)
+			}
+			if _, ok := <-cardCh; ok {
+				t.Errorf("[%s]: card channel should be closed", name)
+			}
+		})
+	}
+}
+
+func TestCardsStreamNoLeakOnAbort(t *testing.T) {
+	defer func(d time.Duration) { baseBackoffDelay = d }(baseBackoffDelay)
+	baseBackoffDelay = time.Millisecond
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			// The run fails once the workers get past the first page.
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `<div class="pager"><a>1</a><a>3</a><span class="next"></span></div><table class="search-result-table"></table>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoGoroutineLeak(t, func() {
+		cfg := Config{
+			Language:               Japanese,
+			HTTPClient:             &http.Client{Transport: rewriteTransport{target}},
+			WorkerStartJitter:      -1,
+			MaxConsecutiveFailures: 1,
+		}
+		cardCh := make(chan Card)
+		go func() {
+			for range cardCh {
+			}
+		}()
+		if err := CardsStream(cfg, cardCh); err == nil {
+			t.Error("expected the run to abort")
+		}
+		// Idle keep-alive connections aren't leaks of the run.
+		http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	})
+}
+
+func TestLastPage_en(t *testing.T) {
+	page := func(numCards, onPage int) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, `<div class="c-search__results-item"><span>%d</span></div><div class="p_cards__results-box"><ul>`, numCards)
+		for i := 0; i < onPage; i++ {
+			b.WriteString("<li><a href=\"/cardlist/list/?cardno=BD/W63-022\"></a></li>")
+		}
+		b.WriteString("</ul></div>")
+		return b.String()
+	}
+	tests := []struct {
+		name             string
+		numCards, onPage int
+		want             int
+	}{
+		{"default page size", 250, 15, 17},
+		{"bigger page size", 250, 100, 3},
+		{"single page", 12, 12, 1},
+		{"no results on page", 250, 0, 17},
+	}
+	for _, tc := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page(tc.numCards, tc.onPage)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := siteConfigs[English].lastPageFunc(doc); got != tc.want {
+			t.Errorf("[%s]: got last page %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLastPageWithoutCount_en(t *testing.T) {
+	tests := []struct {
+		name string
+		// pages maps each page to its number of cards. Every page links to
+		// the other ones in its c-pager, and there's no c-search__results-item.
+		pages     map[string]int
+		robots    string
+		want      int
+		requested []string
+	}{
+		{"last page not full", map[string]int{"1": 15, "2": 15, "3": 3}, "", 3, []string{"1", "2", "3"}},
+		{"last page full", map[string]int{"1": 15, "2": 15}, "", 2, []string{"1", "2"}},
+		{"disallowed page", map[string]int{"1": 15, "2": 15, "3": 3}, "User-agent: *\nDisallow: /cardlist/searchresults/?page=3\n", 2, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		var requested []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			page := r.URL.Query().Get("page")
+			requested = append(requested, page)
+			fmt.Fprint(w, `<div class="p_cards__results-box"><ul>`)
+			for i := 0; i < tt.pages[page]; i++ {
+				fmt.Fprint(w, `<li><a href="/cardlist/list/?cardno=BD/W63-022"></a></li>`)
+			}
+			fmt.Fprint(w, `</ul></div><div class="c-pager">`)
+			for p := 1; p <= len(tt.pages); p++ {
+				if strconv.Itoa(p) != page {
+					fmt.Fprintf(w, `<a href="/cardlist/searchresults/?page=%d">%d</a>`, p, p)
+				}
+			}
+			fmt.Fprint(w, `</div>`)
+		}))
+
+		siteCfg := siteConfigs[English]
+		siteCfg.cardSearchURL = srv.URL + "/cardlist/searchresults/"
+		task := scrapeTask{siteConfig: siteCfg, urlValues: siteCfg.baseURLValues(), httpClient: srv.Client()}
+		if tt.robots != "" {
+			data, err := robotstxt.FromString(tt.robots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.robots = &robotsRules{group: data.FindGroup(robotsAgent)}
+		}
+		last, err := task.getLastPage()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if last != tt.want {
+			t.Errorf("%v: got last page %d, want %d", tt.name, last, tt.want)
+		}
+		if !slices.Equal(requested, tt.requested) {
+			t.Errorf("%v: got requests for pages %v, want %v", tt.name, requested, tt.requested)
+		}
+		// The page fetch workers get the counted pages without fetching them again.
+		for p := 1; p <= last; p++ {
+			if task.counted.take(fmt.Sprintf("%v?page=%d", siteCfg.cardSearchURL, p)) == nil {
+				t.Errorf("%v: page %d wasn't kept", tt.name, p)
+			}
+		}
+	}
+}
+
+func TestSetReducer(t *testing.T) {
+	cardCh := make(chan Card, 4)
+	cardCh <- Card{CardNumber: "BD/W63-025", SetID: "BD"}
+	cardCh <- Card{CardNumber: "SS/WE41-E17", SetID: "SS"}
+	cardCh <- Card{CardNumber: "BD/W63-022", SetID: "BD"}
+	cardCh <- Card{CardNumber: "BD/EN-W03-004", SetID: "BD"}
+	close(cardCh)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var reducer setReducer
+	reducer.reduce(reducerConfig{wg: &wg, cardCh: cardCh})
+
+	if len(reducer.sets) != 2 {
+		t.Fatalf("Got %d sets, want 2", len(reducer.sets))
+	}
+	var got []string
+	for _, c := range reducer.sets["BD"] {
+		got = append(got, c.CardNumber)
+	}
+	want := []string{"BD/EN-W03-004", "BD/W63-022", "BD/W63-025"}
+	if !equalSlice(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func TestConfigImageInterval(t *testing.T) {
+	if got := (Config{}).imageInterval(); got != minTimeBetweenRequests {
+		t.Errorf("got %v: expected the page interval %v", got, minTimeBetweenRequests)
+	}
+	if got := (Config{ImageRequestInterval: 50 * time.Millisecond}).imageInterval(); got != 50*time.Millisecond {
+		t.Errorf("got %v: expected 50ms", got)
+	}
+}
+
+func TestScrapeTaskStats(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	task := scrapeTask{
+		urlValues:    siteConfigs[Japanese].baseURLValues(),
+		cardsFound:   42,
+		pagesScanned: 3,
+		start:        start,
+		end:          start.Add(90 * time.Second),
+	}
+	task.urlValues.Set("expansion", "159")
+
+	stats := task.stats()
+	if stats.Expansion != "159" {
+		t.Errorf("got expansion %q: expected 159", stats.Expansion)
+	}
+	if stats.CardsFound != 42 || stats.PagesScanned != 3 {
+		t.Errorf("got %d cards and %d pages: expected 42 and 3", stats.CardsFound, stats.PagesScanned)
+	}
+	if stats.Duration != 90*time.Second {
+		t.Errorf("got duration %v: expected 1m30s", stats.Duration)
+	}
+}
+
+func TestExtractWorkerForceCardVersion(t *testing.T) {
+	chara := `
+	<th><a href="/cardlist/?cardno=BD/W63-036&amp;l"><img src="/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_036.png" alt="上原ひまり"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(chara))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	selCh := make(chan cardSelection, 1)
+	cardCh := make(chan Card, 1)
+	wg.Add(1)
+	selCh <- cardSelection{sel: doc.Selection}
+	close(selCh)
+	extractWorker(siteConfigs[Japanese], Config{ForceCardVersion: "0"}, nil, &wg, selCh, cardCh)
+	wg.Wait()
+
+	if card := <-cardCh; card.Version != "0" {
+		t.Errorf("got version %q: expected 0", card.Version)
+	}
+}
+
+func TestExtractWorkerCountsParallels(t *testing.T) {
+	row := func(cn string) *goquery.Selection {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(fmt.Sprintf(`
+	<td>
+	<h4><a href="/cardlist/?cardno=%[1]v&amp;l"><span>
+	上原ひまり</span>(<span>%[1]v</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	`, cn)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc.Selection
+	}
+
+	task := &scrapeTask{urlValues: url.Values{}}
+	var wg sync.WaitGroup
+	selCh := make(chan cardSelection, 3)
+	cardCh := make(chan Card, 3)
+	for _, cn := range []string{"BD/W63-036", "BD/W63-036SP", "BD/W63-036SSP"} {
+		wg.Add(1)
+		selCh <- cardSelection{sel: row(cn), task: task}
+	}
+	close(selCh)
+	extractWorker(siteConfigs[Japanese], Config{}, nil, &wg, selCh, cardCh)
+	wg.Wait()
+
+	stats := ScrapeStats{Tasks: []TaskStats{task.stats()}}
+	if got := stats.Parallels(); got != 2 {
+		t.Errorf("got %d parallels: expected 2", got)
+	}
+}
+
+func TestTraitReducer(t *testing.T) {
+	cardCh := make(chan Card, 4)
+	cardCh <- Card{CardNumber: "BD/W63-025", Traits: []string{"音楽", "Poppin'Party"}}
+	cardCh <- Card{CardNumber: "BD/W63-022", Traits: []string{"音楽", "Afterglow"}}
+	cardCh <- Card{CardNumber: "BD/W63-050", Traits: []string{}}
+	cardCh <- Card{CardNumber: "BD/W63-030", Traits: []string{"Poppin'Party"}}
+	close(cardCh)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var reducer traitReducer
+	reducer.reduce(reducerConfig{wg: &wg, cardCh: cardCh})
+
+	if len(reducer.traits) != 3 {
+		t.Fatalf("Got %d traits, want 3: %v", len(reducer.traits), reducer.traits)
+	}
+	var got []string
+	for _, c := range reducer.traits["音楽"] {
+		got = append(got, c.CardNumber)
+	}
+	want := []string{"BD/W63-022", "BD/W63-025"}
+	if !equalSlice(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+	if untagged := reducer.traits[UntaggedTrait]; len(untagged) != 1 || untagged[0].CardNumber != "BD/W63-050" {
+		t.Errorf("Got untagged %v, want BD/W63-050", untagged)
+	}
+}
+
+func TestFetchImageData(t *testing.T) {
+	defer func(d time.Duration) { baseBackoffDelay = d }(baseBackoffDelay)
+	baseBackoffDelay = time.Millisecond
+
+	var pngData, gifData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 2, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 2, 3), palette.Plan9), nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/card.png":
+			w.Write(pngData.Bytes())
+		case "/card.gif":
+			// The JP site has GIF images too.
+			w.Write(gifData.Bytes())
+		case "/blocked.png":
+			w.Write([]byte("<html>blocked</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var oks, bads int
+	getClient := func() (*http.Client, func(), func()) {
+		return srv.Client(), func() { oks++ }, func() { bads++ }
+	}
+
+	data, err := fetchImageData(srv.URL+"/card.png", 0, getClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pngData.Bytes()) {
+		t.Error("got different image data")
+	}
+	if oks != 1 || bads != 0 {
+		t.Errorf("got %d ok and %d bad: expected 1 and 0", oks, bads)
+	}
+
+	if _, err := fetchImageData(srv.URL+"/card.gif", 0, getClient); err != nil {
+		t.Errorf("got %v, expected GIF images to decode", err)
+	}
+
+	oks, bads = 0, 0
+	if _, err := fetchImageData(srv.URL+"/blocked.png", 0, getClient); err == nil {
+		t.Error("expected an error for a page that isn't an image")
+	}
+	if oks != 0 || bads != maxRetries {
+		t.Errorf("got %d ok and %d bad: expected 0 and %d", oks, bads, maxRetries)
+	}
+
+	oks, bads = 0, 0
+	if _, err := fetchImageData(srv.URL+"/missing.png", 0, getClient); !errors.Is(err, errImageNotFound) {
+		t.Errorf("got %v, expected errImageNotFound", err)
+	}
+	if oks != 1 || bads != 0 {
+		t.Errorf("got %d ok and %d bad: a 404 shouldn't be retried", oks, bads)
+	}
+}
+
+func TestDownloadImage(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 2, 3))); err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(pngData.Bytes())
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	const interval = 50 * time.Millisecond
+	// HTTPClient replaces the direct connection and the image interval paces
+	// the requests.
+	cfg := Config{
+		HTTPClient:           &http.Client{Transport: rewriteTransport{target}},
+		ImageRequestInterval: interval,
+	}
+	start := time.Now()
+	data, err := DownloadImage("http://images.example/card.png", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pngData.Bytes()) {
+		t.Error("got different image data")
+	}
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("took %v: expected to wait the image interval of %v", elapsed, interval)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests: expected 1", n)
+	}
+}
+
+func TestAlternateImageURL(t *testing.T) {
+	alt, ok := alternateImageURL("https://en.ws-tcg.com/wp/wp-content/images/cardimages/f/fs_s64/FS_BCS_2019_03.png")
+	if expected := "https://ws-tcg.com/wordpress/wp-content/images/cardlist/f/fs_s64/fs_bcs_2019_03.png"; !ok || alt != expected {
+		t.Errorf("got %q, expected %q", alt, expected)
+	}
+	if _, ok := alternateImageURL("https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_025.png"); ok {
+		t.Error("JP images have no alternate")
+	}
+}
+
+func TestKeepCardColors(t *testing.T) {
+	red := Card{CardNumber: "BD/W63-022", Color: "RED"}
+	purple := Card{CardNumber: "PY/S38-120", Color: "PURPLE"}
+	blue := Card{CardNumber: "BD/W63-036", Color: "BLUE"}
+
+	if cfg := (Config{}); !cfg.keepCard(red) || !cfg.keepCard(purple) {
+		t.Error("No colors should keep every card")
+	}
+	cfg := Config{Colors: []string{"RED", "PURPLE"}}
+	if !cfg.keepCard(red) || !cfg.keepCard(purple) {
+		t.Error("Red and purple cards should be kept")
+	}
+	if cfg.keepCard(blue) {
+		t.Error("Blue card should be dropped")
+	}
+}
+
+func TestTypesFilter(t *testing.T) {
+	climax := Card{CardNumber: "BD/W63-050", Type: "CX"}
+	event := Card{CardNumber: "BD/W63-049", Type: "EV"}
+	if cfg := (Config{Types: []string{"CX"}}); !cfg.keepCard(climax) || cfg.keepCard(event) {
+		t.Error("Only the climax should be kept")
+	}
+
+	v, err := searchValues(Config{Language: Japanese, Types: []string{"CX"}}, siteConfigs[Japanese])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Get("card_kind"); got != "4" {
+		t.Errorf("got card_kind %q, want 4", got)
+	}
+	v, err = searchValues(Config{Language: Japanese, Types: []string{"CX", "EV"}}, siteConfigs[Japanese])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Has("card_kind") {
+		t.Error("card_kind only takes one type")
+	}
+	v, err = searchValues(Config{Language: English, Types: []string{"CX"}}, siteConfigs[English])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Has("card_kind") {
+		t.Error("the EN search can't filter by type")
+	}
+}
+
+func TestCardsStreamUnsupportedColor(t *testing.T) {
+	cardCh := make(chan Card)
+	if err := CardsStream(Config{Language: Japanese, Colors: []string{"PINK"}}, cardCh); err == nil {
+		t.Error("expected an error for an unsupported color")
+	}
+	if _, ok := <-cardCh; ok {
+		t.Error("card channel should be closed")
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	// A nil tracker is a no-op.
+	var none *progressTracker
+	none.update(func(p *Progress) { p.Cards++ })
+	if newProgressTracker(nil) != nil {
+		t.Error("expected no tracker without a callback")
+	}
+
+	var got []Progress
+	tracker := newProgressTracker(func(p Progress) { got = append(got, p) })
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.update(func(p *Progress) { p.Cards++ })
+		}()
+	}
+	wg.Wait()
+	tracker.update(func(p *Progress) { p.TotalPages = 3 })
+
+	if len(got) != 11 {
+		t.Fatalf("got %d reports: expected 11", len(got))
+	}
+	if last := got[len(got)-1]; last.Cards != 10 || last.TotalPages != 3 {
+		t.Errorf("got %+v: expected 10 cards and 3 pages", last)
+	}
+}
+
+func TestParseSelectOptions(t *testing.T) {
+	page := `
+<select name="title" id="title">
+	<option value="">All</option>
+	<option value="159">Tokyo Revengers</option>
+	<option value="27">BanG Dream!</option>
+</select>
+<select name="expansion" id="expansion">
+	<option value="1">Something else</option>
+</select>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles, err := parseSelectOptions(doc.Selection, "title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 2 || titles[159] != "Tokyo Revengers" || titles[27] != "BanG Dream!" {
+		t.Errorf("got %v: expected the two titles", titles)
+	}
+
+	if _, err := parseSelectOptions(doc.Selection, "missing"); err == nil {
+		t.Error("expected an error for a missing select")
+	}
+}
+
+func TestTitleListUnsupported(t *testing.T) {
+	if _, err := TitleList(Config{Language: Japanese}); err == nil {
+		t.Error("expected an error listing JP titles")
+	}
+}
+
+func TestConfigStartDelay(t *testing.T) {
+	rng := newLockedRand(0)
+	for i := 0; i < 100; i++ {
+		if d := (Config{}).startDelay(rng); d < 0 || d >= defaultWorkerStartJitter {
+			t.Fatalf("got %v: expected a delay within %v", d, defaultWorkerStartJitter)
+		}
+		if d := (Config{WorkerStartJitter: time.Second}).startDelay(rng); d < 0 || d >= time.Second {
+			t.Fatalf("got %v: expected a delay within 1s", d)
+		}
+	}
+	if d := (Config{WorkerStartJitter: -1}).startDelay(rng); d != 0 {
+		t.Errorf("got %v: expected no delay", d)
+	}
+}
+
+func TestCardNumberReducer(t *testing.T) {
+	cardCh := make(chan Card, 3)
+	cardCh <- Card{CardNumber: "BD/W63-025"}
+	cardCh <- Card{CardNumber: "BD/EN-W03-004"}
+	cardCh <- Card{CardNumber: "BD/W63-022"}
+	close(cardCh)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var reducer cardNumberReducer
+	reducer.reduce(reducerConfig{wg: &wg, cardCh: cardCh})
+
+	want := []string{"BD/EN-W03-004", "BD/W63-022", "BD/W63-025"}
+	if !equalSlice(reducer.numbers, want) {
+		t.Errorf("Got %v, want %v", reducer.numbers, want)
+	}
+}
+
+func TestEnResultLinks(t *testing.T) {
+	text := `
+<div class="p_cards__results-box">
+	<ul>
+		<li><a href="/cardlist/list/?cardno=BD/EN-W03-004">"A Nice Change" Kanon Matsubara</a></li>
+		<li><a href="/cardlist/list/?cardno=BD/EN-W03-005">Misaki Okusawa</a></li>
+	</ul>
+</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/cardlist/list/?cardno=BD/EN-W03-004", "/cardlist/list/?cardno=BD/EN-W03-005"}
+	if got := enResultLinks(doc); !equalSlice(got, want) {
+		t.Errorf("text layout: got %v, want %v", got, want)
+	}
+
+	f, err := os.Open("mockws-en/results-gallery.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err = goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"/cardlist/list/?cardno=BD/EN-W03-004",
+		"/cardlist/list/?cardno=BD/EN-W03-005",
+		"/cardlist/list/?cardno=BD/EN-W03-006",
+	}
+	if got := enResultLinks(doc); !equalSlice(got, want) {
+		t.Errorf("gallery layout: got %v, want %v", got, want)
+	}
+}
+
+func TestRandSeed(t *testing.T) {
+	cfg := Config{RandSeed: 42, WorkerStartJitter: time.Second}
+	a, b := newLockedRand(cfg.RandSeed), newLockedRand(cfg.RandSeed)
+	for i := 0; i < 10; i++ {
+		if da, db := cfg.startDelay(a), cfg.startDelay(b); da != db {
+			t.Fatalf("got %v and %v: expected the same delays with the same seed", da, db)
+		}
+	}
+}
+
+func TestPageFetchWorkerPastDeadline(t *testing.T) {
+	task := &scrapeTask{
+		pageURLCh:  make(chan string, 2),
+		wgPageScan: &sync.WaitGroup{},
+		deadline:   time.Now().Add(-time.Second),
+	}
+	task.wgPageScan.Add(2)
+	task.pageURLCh <- "https://ws-tcg.com/cardlist/search?page=1"
+	task.pageURLCh <- "https://ws-tcg.com/cardlist/search?page=2"
+	close(task.pageURLCh)
+
+	pageFetchWorker(0, task, 0)
+	// Would hang if the skipped pages weren't marked as done.
+	task.wgPageScan.Wait()
+	if task.pagesSkipped != 2 {
+		t.Errorf("got %d skipped pages: expected 2", task.pagesSkipped)
+	}
+}
+
+func TestFetchDetailPageRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`<div class="p-cards__detail-wrapper"><p class="number">BD/EN-W03-004</p></div>`))
+	}))
+	defer srv.Close()
+
+	task := &scrapeTask{siteConfig: siteConfigs[English], httpClient: srv.Client()}
+	details, retries, err := fetchDetailPage(task, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Length() != 1 {
+		t.Errorf("got %d details blocks, expected 1", details.Length())
+	}
+	if retries != 1 {
+		t.Errorf("got %d retries, expected 1", retries)
+	}
+}
+
+func TestFetchDetailPageRemoved(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/gone":
+			http.NotFound(w, r)
+		case "/empty":
+			w.Write([]byte(`<div class="p-cards__detail-wrapper"><div class="p-cards__detail-textarea"><p class="number"></p></div></div>`))
+		}
+	}))
+	defer srv.Close()
+
+	task := &scrapeTask{siteConfig: siteConfigs[English], httpClient: srv.Client()}
+	for _, path := range []string{"/gone", "/empty"} {
+		calls = 0
+		if _, _, err := fetchDetailPage(task, srv.URL+path); !errors.Is(err, errCardRemoved) {
+			t.Errorf("%v: got %v, expected errCardRemoved", path, err)
+		}
+		if calls != 1 {
+			t.Errorf("%v: got %d requests, a removed card shouldn't be retried", path, calls)
+		}
+	}
+}
+
+func TestBoosterReducerDedupe(t *testing.T) {
+	for _, dedupe := range []bool{false, true} {
+		cardCh := make(chan Card, 3)
+		cardCh <- Card{CardNumber: "BD/W63-036", Release: "W63"}
+		cardCh <- Card{CardNumber: "BD/W63-036", Release: "W63"}
+		cardCh <- Card{CardNumber: "BD/W63-037", Release: "W63"}
+		close(cardCh)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		reducer := boosterReducer{dedupe: dedupe}
+		reducer.reduce(reducerConfig{wg: &wg, cardCh: cardCh})
+
+		want := 3
+		if dedupe {
+			want = 2
+		}
+		if got := len(reducer.boosterMap["W63"].Cards); got != want {
+			t.Errorf("dedupe=%v: got %d cards, want %d", dedupe, got, want)
+		}
+	}
+}
+
+func TestCardPermalink(t *testing.T) {
+	siteCfg := siteConfigs[English]
+	tests := map[string]string{
+		"BD/EN-W03-004":  "https://en.ws-tcg.com/cardlist/list/?cardno=BD/EN-W03-004",
+		"BD/W63-036SSP+": "https://en.ws-tcg.com/cardlist/list/?cardno=BD/W63-036SSP%2B",
+	}
+	for cn, want := range tests {
+		if got := cardPermalink(siteCfg, cn); got != want {
+			t.Errorf("cardPermalink(%q) = %q, want %q", cn, got, want)
+		}
+	}
+}
+
+func TestCardDetailURL(t *testing.T) {
+	tests := []struct {
+		card Card
+		want string
+	}{
+		{Card{CardNumber: "BD/W63-036SSP+", Language: "en"}, "https://en.ws-tcg.com/cardlist/list/?cardno=BD/W63-036SSP%2B"},
+		{Card{CardNumber: "BD/W63-036SPMa", Language: "ja"}, "https://ws-tcg.com/cardlist/?cardno=BD/W63-036SPMa"},
+		{Card{CardNumber: "BD/W63-036", Language: "fr"}, ""},
+		{Card{Language: "ja"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.card.DetailURL(); got != tt.want {
+			t.Errorf("DetailURL(%q, %q) = %q, want %q", tt.card.CardNumber, tt.card.Language, got, tt.want)
+		}
+	}
+}
+
+func TestFindCard_jp(t *testing.T) {
+	row := func(cn string) string {
+		return fmt.Sprintf(`<tr>
+	<th><a href="/cardlist/?cardno=%[1]s&amp;l"><img src="/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_036.png" alt="上原ひまり"></a></th>
+	<td>
+	<h4><a href="/cardlist/?cardno=%[1]s&amp;l"><span>
+	上原ひまり</span>(<span>%[1]s</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td></tr>`, cn)
+	}
+	page := `<table class="search-result-table">` + row("BD/W63-036SP") + row("BD/W63-036") + `</table>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card, err := findCard(siteConfigs[Japanese], doc, "BD/W63-036")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.CardNumber != "BD/W63-036" {
+		t.Errorf("got %v: expected the exact match BD/W63-036", card.CardNumber)
+	}
+	if _, err := findCard(siteConfigs[Japanese], doc, "BD/W63-037"); err == nil {
+		t.Error("expected an error for a card that isn't on the page")
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	tracker := newFailureTracker(3)
+	tracker.failure()
+	tracker.failure()
+	tracker.success()
+	tracker.failure()
+	tracker.failure()
+	if tracker.aborted() {
+		t.Error("a success should reset the count")
+	}
+	tracker.failure()
+	if !tracker.aborted() {
+		t.Error("expected the tracker to trip after 3 failures in a row")
+	}
+
+	var none *failureTracker
+	none.failure()
+	if none.aborted() || newFailureTracker(0) != nil {
+		t.Error("expected no tracker to never abort")
+	}
+}
+
+// rewriteTransport sends every request to the test server at target.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCardsStreamHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<table class="search-result-table"><tr>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	</tr></table>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:          Japanese,
+		HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter: -1,
+	}
+	cardCh := make(chan Card, 10)
+	if err := CardsStream(cfg, cardCh); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for card := range cardCh {
+		got = append(got, card.CardNumber)
+	}
+	if want := []string{"BD/W63-036"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCardsStreamGivesUpOnPage_jp(t *testing.T) {
+	var fetches int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cardlist/search" && r.URL.Query().Get("page") == "1" {
+			atomic.AddInt64(&fetches, 1)
+		}
+		// A layout without the results table, which retrying won't fix.
+		fmt.Fprint(w, `<div class="results"></div>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:          Japanese,
+		HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter: -1,
+	}
+	cardCh := make(chan Card, 10)
+	stats, err := CardsStreamWithStats(cfg, cardCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last page request plus the first fetch and maxPageRequeues tries.
+	if got, want := atomic.LoadInt64(&fetches), int64(maxPageRequeues+2); got != want {
+		t.Errorf("got %d page fetches, want %d", got, want)
+	}
+	if len(stats.Errors) != 1 || stats.Errors[0].Stage != StagePage {
+		t.Errorf("got errors %+v, want the page given up on", stats.Errors)
+	}
+}
+
+func TestCardsStreamRetriesDontAbort_jp(t *testing.T) {
+	var pageFetches int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" && atomic.AddInt64(&pageFetches, 1) == 2 {
+			// The first worker fetch fails once, then the retry works.
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `<table class="search-result-table"></table>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:               Japanese,
+		HTTPClient:             &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter:      -1,
+		MaxConsecutiveFailures: 1,
+	}
+	if err := CardsStream(cfg, make(chan Card, 10)); err != nil {
+		t.Errorf("got %v, expected a failed attempt of a page that then worked not to abort", err)
+	}
+}
+
+func TestScrapeSinglePage(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `<table class="search-result-table"><tr>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	</tr></table>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:   Japanese,
+		HTTPClient: &http.Client{Transport: rewriteTransport{target}},
+	}
+	cards, err := ScrapeSinglePage(cfg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "page=3" {
+		t.Errorf("got query %q, expected page=3", query)
+	}
+	if len(cards) != 1 || cards[0].CardNumber != "BD/W63-036" {
+		t.Errorf("got %v, expected BD/W63-036", cards)
+	}
+}
+
+func TestCardsStreamSkipExpansion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<table class="search-result-table"><tr>
+	<td>
+	<h4><a href="/cardlist/?cardno=BD/W63-036&amp;l"><span>
+	上原ひまり</span>(<span>BD/W63-036</span>)</a> -「バンドリ！ ガールズバンドパーティ！」Vol.2<br/></h4>
+	<span class="unit">種類：キャラ</span>
+	</td>
+	</tr></table>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, skip := range []bool{false, true} {
+		var asked []int
+		cfg := Config{
+			Language:          Japanese,
+			ExpansionNumber:   159,
+			HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+			WorkerStartJitter: -1,
+			SkipExpansion: func(expansion, expected int) bool {
+				asked = append(asked, expansion)
+				return skip
+			},
+		}
+		cardCh := make(chan Card, 10)
+		if err := CardsStream(cfg, cardCh); err != nil {
+			t.Fatal(err)
+		}
+		var cards []Card
+		for card := range cardCh {
+			cards = append(cards, card)
+		}
+		if !slices.Equal(asked, []int{159}) {
+			t.Errorf("skip=%v: SkipExpansion got %v, expected [159]", skip, asked)
+		}
+		if skip && len(cards) != 0 {
+			t.Errorf("got %d cards from a skipped expansion", len(cards))
+		}
+		if !skip && (len(cards) != 1 || cards[0].ExpansionID != 159) {
+			t.Errorf("got %v, expected one card with ExpansionID 159", cards)
+		}
+	}
+}
+
+func TestCardsStreamSkipCardNumber_en(t *testing.T) {
+	var details int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/cardlist/list/") {
+			details++
+			return
+		}
+		fmt.Fprint(w, `<div class="c-search__results-item"><span>1</span></div>
+	<div class="p_cards__results-box"><ul><li><a href="/cardlist/list/?cardno=BD/EN-W03-004"></a></li></ul></div>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var skipped []string
+	cfg := Config{
+		Language:          English,
+		HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter: -1,
+		SkipCardNumber: func(cardNumber string) bool {
+			skipped = append(skipped, cardNumber)
+			return true
+		},
+	}
+	cardCh := make(chan Card, 10)
+	if err := CardsStream(cfg, cardCh); err != nil {
+		t.Fatal(err)
+	}
+	for card := range cardCh {
+		t.Errorf("got %v, expected no cards", card.CardNumber)
+	}
+	if want := []string{"BD/EN-W03-004"}; !slices.Equal(skipped, want) {
+		t.Errorf("got skipped %v, want %v", skipped, want)
+	}
+	if details != 0 {
+		t.Errorf("got %d detail page requests, expected none", details)
+	}
+}
+
+func TestCardsStreamMissingCards_en(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div class="c-search__results-item"><span>3</span></div>
+	<div class="p_cards__results-box"><ul></ul></div>`)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:          English,
+		HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter: -1,
+	}
+	cardCh := make(chan Card, 10)
+	stats, err := CardsStreamWithStats(cfg, cardCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Tasks) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(stats.Tasks))
+	}
+	ts := stats.Tasks[0]
+	if ts.ExpectedCards != 3 || ts.MissingCards != 3 || !ts.shortfall() {
+		t.Errorf("got %d expected and %d missing cards, want 3 and 3", ts.ExpectedCards, ts.MissingCards)
+	}
+}
+
+func TestExpectedCards(t *testing.T) {
+	tests := []struct {
+		name string
+		task scrapeTask
+		want int
+	}{
+		{"result count", scrapeTask{lastPage: 2, resultCount: 150, siteConfig: siteConfig{pageSize: 100}}, 150},
+		{"full pages", scrapeTask{lastPage: 3, siteConfig: siteConfig{pageSize: 100}}, 200},
+		{"unknown page size", scrapeTask{lastPage: 3}, 0},
+		{"skipped", scrapeTask{resultCount: 150}, 0},
+		{"page start", scrapeTask{lastPage: 2, resultCount: 150, pageStart: 2}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.task.expectedCards(); got != tt.want {
+			t.Errorf("%v: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchGET(t *testing.T) {
+	var method, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, query = r.Method, r.URL.RawQuery
+	}))
+	defer srv.Close()
+
+	values := url.Values{"expansion": {"159"}, "keyword": {"BD/W63"}}
+	resp, err := search(srv.Client(), srv.URL+"/cardlist/search?page=2", values, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "expansion=159&keyword=BD%2FW63&page=2"; method != http.MethodGet || query != want {
+		t.Errorf("got %v ?%v, want GET ?%v", method, query, want)
+	}
+
+	// Searching the URL of a GET response again doesn't repeat the values.
+	resp, err = search(srv.Client(), resp.Request.URL.String(), values, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "expansion=159&keyword=BD%2FW63&page=2"; query != want {
+		t.Errorf("got ?%v searching again, want ?%v", query, want)
+	}
+
+	resp, err = search(srv.Client(), srv.URL+"/cardlist/search?page=2", values, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if method != http.MethodPost || query != "page=2" {
+		t.Errorf("got %v ?%v, want POST ?page=2", method, query)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AbilityCost is the cost between brackets of an ability, e.g.
+// [(1)【REST】this card] is Stock 1 and Rest.
+type AbilityCost struct {
+	// Ability is the index of the ability in Card.Text.
+	Ability int `json:"ability"`
+	// Stock is the number of stock to pay.
+	Stock int `json:"stock,omitempty"`
+	// Rest is true if a character, usually the card itself, has to be rested.
+	Rest bool `json:"rest,omitempty"`
+	// Discard is the number of cards to put from hand into the waiting room.
+	Discard int `json:"discard,omitempty"`
+	// Clock is the number of cards to put into the clock.
+	Clock int `json:"clock,omitempty"`
+	// Raw is the text between the brackets.
+	Raw string `json:"raw"`
+}
+
+// costFolder turns the JP full width brackets and digits into ASCII, leaving
+// the kana alone unlike width.Narrow.
+var costFolder = strings.NewReplacer(
+	"［", "[", "］", "]", "（", "(", "）", ")",
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9",
+)
+
+var (
+	// costBracketRE matches the bracket right after the ability marker and its
+	// other markers or short keyword name, e.g. 【ACT】Brainstorm [...], so
+	// the trigger icons written as [SOUL] in the text don't count.
+	costBracketRE = regexp.MustCompile(`^\s*【(?:AUTO|ACT|自|起)】(?:\s*【[^】]*】)*\s*[^\[【。.,、:：]{0,30}?\s*\[([^\[\]]*)\]`)
+	costStockRE   = regexp.MustCompile(`\((\d+)\)`)
+	costRestRE    = regexp.MustCompile(`【REST】|【レスト】`)
+	costDiscardRE = regexp.MustCompile(`(?i)(?:put|discard) (\d+|a|an) (?:\S+ )*?cards? from your hand|手札の?\S*?を(\d+)枚控え室に置く`)
+	costClockRE   = regexp.MustCompile(`(?i)(?:put|place) (?:the top )?(\d+|a|an)? ?(?:\S+ )*?cards?.*into your clock|(\d+)?枚?\S*クロックに置く`)
+)
+
+// costCount returns the number in a cost match, where "a", "an" and no number
+// mean 1.
+func costCount(groups ...string) int {
+	for _, g := range groups {
+		if n, err := strconv.Atoi(g); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// abilityCosts parses the cost of every 【AUTO】 and 【ACT】 ability of text
+// that has one. Both EN brackets and JP full width brackets are understood.
+func abilityCosts(text []string) []AbilityCost {
+	var costs []AbilityCost
+	for i, line := range text {
+		m := costBracketRE.FindStringSubmatch(costFolder.Replace(line))
+		if m == nil {
+			continue
+		}
+		raw := strings.TrimSpace(m[1])
+		cost := AbilityCost{Ability: i, Raw: raw}
+		if s := costStockRE.FindStringSubmatch(raw); s != nil {
+			cost.Stock, _ = strconv.Atoi(s[1])
+		}
+		cost.Rest = costRestRE.MatchString(raw)
+		if d := costDiscardRE.FindStringSubmatch(raw); d != nil {
+			cost.Discard = costCount(d[1:]...)
+		}
+		if c := costClockRE.FindStringSubmatch(raw); c != nil {
+			cost.Clock = costCount(c[1:]...)
+		}
+		costs = append(costs, cost)
+	}
+	return costs
+}
@@ -0,0 +1,34 @@
+package fetch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAbilityCosts(t *testing.T) {
+	text := []string{
+		"【AUTO】At the beginning of your climax phase, choose 1 of your 《Music》 characters, and that character gets +1000 power until end of turn.",
+		"【ACT】Brainstorm [(1)【REST】this card] Flip over 4 cards from the top of your deck, and put it into your waiting room. For each climax revealed among those cards, draw up to 1 card.",
+		"【ACT】 [(2) 【REST】 this card] Put the top card of your clock into your waiting room.",
+		"【自】［(1)］ このカードがアタックした時 、あなたはコストを払ってよい。",
+		"【自】 アンコール ［手札のキャラを１枚控え室に置く］",
+		"【AUTO】 [(1) Put 1 card from your hand into your waiting room] When this card attacks, you may pay the cost.",
+		"【自】［このカードを【レスト】する］ あなたのクライマックスがクライマックス置場に置かれた時、あなたはコストを払ってよい。",
+		"【ACT】 [Put the top card of your deck into your clock] This card gets +1000 power.",
+		"（[RETURN]：このカードがトリガーした時、あなたは相手のキャラを1枚選び、手札に戻す）",
+		"【AUTO】 When this card's battle opponent becomes 【REVERSE】, you may put that character into your stock. ([SOUL]: When this card triggers)",
+		"【CONT】 [GATE] All of your characters get +1000 power.",
+	}
+	want := []AbilityCost{
+		{Ability: 1, Stock: 1, Rest: true, Raw: "(1)【REST】this card"},
+		{Ability: 2, Stock: 2, Rest: true, Raw: "(2) 【REST】 this card"},
+		{Ability: 3, Stock: 1, Raw: "(1)"},
+		{Ability: 4, Discard: 1, Raw: "手札のキャラを1枚控え室に置く"},
+		{Ability: 5, Stock: 1, Discard: 1, Raw: "(1) Put 1 card from your hand into your waiting room"},
+		{Ability: 6, Rest: true, Raw: "このカードを【レスト】する"},
+		{Ability: 7, Clock: 1, Raw: "Put the top card of your deck into your clock"},
+	}
+	if got := abilityCosts(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DeckCard is a card of the deckformat export. It isn't the import format of
+// a particular deck-building tool: it's this package's own flat shape, with
+// the fields such tools usually ask for, so it maps onto a tool's import in
+// one step. The export is a JSON array of objects with exactly these keys, in
+// this order and always present, as deck_test.go pins.
+//
+//	code     string   card number, e.g. "BD/W63-036"
+//	name     string
+//	image    string   image URL
+//	type     string   "Character", "Event" or "Climax"
+//	level    int      0 when the card has none
+//	cost     int
+//	color    string   lowercase, e.g. "red"
+//	soul     int
+//	power    int
+//	triggers []string lowercase, e.g. ["soul", "soul"]
+type DeckCard struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Image    string   `json:"image"`
+	Type     string   `json:"type"`
+	Level    int      `json:"level"`
+	Cost     int      `json:"cost"`
+	Color    string   `json:"color"`
+	Soul     int      `json:"soul"`
+	Power    int      `json:"power"`
+	Triggers []string `json:"triggers"`
+}
+
+var deckCardTypes = map[string]string{
+	"CH": "Character",
+	"EV": "Event",
+	"CX": "Climax",
+}
+
+// ToDeckCard converts c to the deck-building tool shape.
+func ToDeckCard(c Card) DeckCard {
+	triggers := make([]string, 0, len(c.Triggers))
+	for _, t := range c.Triggers {
+		triggers = append(triggers, strings.ToLower(t))
+	}
+	return DeckCard{
+		Code:     c.CardNumber,
+		Name:     c.Name,
+		Image:    c.ImageURL,
+		Type:     deckCardTypes[c.Type],
+		Level:    atoiOrZero(c.Level),
+		Cost:     atoiOrZero(c.Cost),
+		Color:    strings.ToLower(c.Color),
+		Soul:     atoiOrZero(c.Soul),
+		Power:    atoiOrZero(c.Power),
+		Triggers: triggers,
+	}
+}
+
+// atoiOrZero is strconv.Atoi returning 0 for empty or invalid numbers.
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToDeckCard(t *testing.T) {
+	card := Card{
+		CardNumber: "BD/W63-036",
+		Name:       "上原ひまり",
+		ImageURL:   "https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_036.png",
+		Type:       "CH",
+		Level:      "2",
+		Cost:       "1",
+		Color:      "RED",
+		Soul:       "1",
+		Power:      "6000",
+		Triggers:   []string{"SOUL"},
+	}
+	got := ToDeckCard(card)
+	if got.Code != card.CardNumber || got.Name != card.Name || got.Image != card.ImageURL {
+		t.Errorf("got %+v: expected the card number, name and image to be copied", got)
+	}
+	if got.Type != "Character" || got.Color != "red" {
+		t.Errorf("got type %q and color %q: expected Character and red", got.Type, got.Color)
+	}
+	if got.Level != 2 || got.Cost != 1 || got.Soul != 1 || got.Power != 6000 {
+		t.Errorf("got %+v: expected numbers to be converted", got)
+	}
+	if !equalSlice(got.Triggers, []string{"soul"}) {
+		t.Errorf("got triggers %v: expected [soul]", got.Triggers)
+	}
+
+	climax := ToDeckCard(Card{Type: "CX", Triggers: []string{}})
+	if climax.Type != "Climax" || climax.Level != 0 || climax.Triggers == nil {
+		t.Errorf("got %+v: expected an empty climax", climax)
+	}
+
+	// The keys and their order are the format's contract.
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":"BD/W63-036","name":"上原ひまり","image":"https://ws-tcg.com/wordpress/wp-content/images/cardlist/b/bd_w63/bd_w63_036.png","type":"Character","level":2,"cost":1,"color":"red","soul":1,"power":6000,"triggers":["soul"]}`
+	if string(data) != want {
+		t.Errorf("got %s\nwant %s", data, want)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange is a single field that differs between two versions of a card.
+type FieldChange struct {
+	// Field is the JSON name of the field.
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// CardChange lists the fields that changed on a card present on both sides.
+type CardChange struct {
+	CardNumber string        `json:"cardNumber"`
+	Language   string        `json:"language"`
+	Fields     []FieldChange `json:"fields"`
+}
+
+// CardDiff is the result of DiffCards. Every list is sorted by card number.
+type CardDiff struct {
+	Added   []Card       `json:"added"`
+	Removed []Card       `json:"removed"`
+	Changed []CardChange `json:"changed"`
+}
+
+type cardKey struct {
+	cardNumber string
+	language   string
+}
+
+func keyOf(card Card) cardKey {
+	return cardKey{card.CardNumber, card.Language}
+}
+
+// DiffCards compares two sets of cards, matching them by CardNumber and
+// Language. Fields that aren't exported to JSON are ignored.
+func DiffCards(oldCards, newCards []Card) CardDiff {
+	diff := CardDiff{Added: []Card{}, Removed: []Card{}, Changed: []CardChange{}}
+	olds := make(map[cardKey]Card, len(oldCards))
+	for _, card := range oldCards {
+		olds[keyOf(card)] = card
+	}
+	news := make(map[cardKey]Card, len(newCards))
+	for _, card := range newCards {
+		news[keyOf(card)] = card
+	}
+
+	for key, card := range news {
+		old, ok := olds[key]
+		if !ok {
+			diff.Added = append(diff.Added, card)
+			continue
+		}
+		if fields := diffFields(old, card, false); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, CardChange{
+				CardNumber: key.cardNumber,
+				Language:   key.language,
+				Fields:     fields,
+			})
+		}
+	}
+	for key, card := range olds {
+		if _, ok := news[key]; !ok {
+			diff.Removed = append(diff.Removed, card)
+		}
+	}
+
+	sortCards(diff.Added)
+	sortCards(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		a, b := diff.Changed[i], diff.Changed[j]
+		if a.CardNumber != b.CardNumber {
+			return a.CardNumber < b.CardNumber
+		}
+		return a.Language < b.Language
+	})
+	return diff
+}
+
+func sortCards(cards []Card) {
+	sort.Slice(cards, func(i, j int) bool {
+		if cards[i].CardNumber != cards[j].CardNumber {
+			return cards[i].CardNumber < cards[j].CardNumber
+		}
+		return cards[i].Language < cards[j].Language
+	})
+}
+
+// diffFields returns the JSON fields that differ between a and b, in struct
+// order. Unless strictSlices is set, nil and empty slices are considered
+// equal, since cards written before empty slices were always [] have nulls
+// instead. RetryCount describes the scrape rather than the card, so it's
+// skipped.
+func diffFields(a, b Card, strictSlices bool) []FieldChange {
+	var fields []FieldChange
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "retryCount" {
+			continue
+		}
+		fa, fb := va.Field(i), vb.Field(i)
+		if !strictSlices && fa.Kind() == reflect.Slice && fa.Len() == 0 && fb.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			fields = append(fields, FieldChange{Field: name, Old: fa.Interface(), New: fb.Interface()})
+		}
+	}
+	return fields
+}
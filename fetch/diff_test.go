@@ -0,0 +1,43 @@
+package fetch
+
+import (
+	"testing"
+)
+
+func TestDiffCards(t *testing.T) {
+	oldCards := []Card{
+		{CardNumber: "BD/W63-036", Language: "JP", Power: "6000", Text: []string{"a"}},
+		{CardNumber: "BD/W63-037", Language: "JP"},
+		{CardNumber: "BD/W63-038", Language: "JP", Traits: nil},
+	}
+	newCards := []Card{
+		{CardNumber: "BD/W63-038", Language: "JP", Traits: []string{}},
+		{CardNumber: "BD/W63-036", Language: "JP", Power: "7000", Text: []string{"b"}},
+		{CardNumber: "BD/W63-037", Language: "EN"},
+	}
+
+	diff := DiffCards(oldCards, newCards)
+
+	if len(diff.Added) != 1 || diff.Added[0].Language != "EN" {
+		t.Errorf("got added %+v: expected the EN BD/W63-037", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].CardNumber != "BD/W63-037" || diff.Removed[0].Language != "JP" {
+		t.Errorf("got removed %+v: expected the JP BD/W63-037", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("got %d changed cards: expected 1", len(diff.Changed))
+	}
+	change := diff.Changed[0]
+	if change.CardNumber != "BD/W63-036" {
+		t.Errorf("got %v: expected BD/W63-036", change.CardNumber)
+	}
+	if len(change.Fields) != 2 {
+		t.Fatalf("got fields %+v: expected power and text", change.Fields)
+	}
+	if change.Fields[0].Field != "power" || change.Fields[0].Old != "6000" || change.Fields[0].New != "7000" {
+		t.Errorf("got %+v: expected power 6000 -> 7000", change.Fields[0])
+	}
+	if change.Fields[1].Field != "text" {
+		t.Errorf("got %v: expected text", change.Fields[1].Field)
+	}
+}
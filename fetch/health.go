@@ -0,0 +1,163 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/publicsuffix"
+)
+
+// SiteCheck is a selector CheckSite looked for on one of the site's pages, or
+// its check of the search with Config.UseGET.
+type SiteCheck struct {
+	URL      string
+	Selector string
+	// Err is why the page couldn't be fetched or didn't have the selector.
+	// Nil if the selector was found.
+	Err error
+}
+
+// getFilterCheck is the SiteCheck.Selector of the check of Config.UseGET.
+const getFilterCheck = "expansion filter with GET"
+
+// siteCheckPage is a page CheckSite fetches and the selectors it expects.
+type siteCheckPage struct {
+	url       string
+	values    url.Values
+	selectors []string
+	// search is true for the search pages, which Config.UseGET applies to.
+	search bool
+}
+
+// siteCheckPages returns the pages the scraper relies on: the card list with
+// the search form and the first page of the search results.
+func siteCheckPages(c siteConfig) []siteCheckPage {
+	return []siteCheckPage{
+		{c.cardListURL, url.Values{}, []string{"select#expansion"}, false},
+		{fmt.Sprintf("%v?page=%d", c.cardSearchURL, 1), c.baseURLValues(), c.resultSelectors, true},
+	}
+}
+
+// CheckSite fetches the card list and a search results page of cfg.Language
+// and reports whether they still have the selectors extraction relies on,
+// to notice a layout change before a long run. With cfg.UseGET it also checks
+// that a GET search of an expansion finds as much as the site's POST form, as
+// a GET the site doesn't filter still has results: the whole catalog. It
+// connects directly unless cfg.HTTPClient is set. The error is only for an
+// unsupported language.
+func CheckSite(cfg Config) ([]SiteCheck, error) {
+	siteCfg, ok := siteConfigs[cfg.Language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %v", cfg.Language)
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %v", err)
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: lastPageTimeout, Jar: jar}
+	}
+
+	var checks []SiteCheck
+	var listDoc *goquery.Document
+	for _, page := range siteCheckPages(siteCfg) {
+		doc, err := checkPage(client, page.url, page.values, page.search && cfg.UseGET)
+		if !page.search {
+			listDoc = doc
+		}
+		for _, selector := range page.selectors {
+			check := SiteCheck{URL: page.url, Selector: selector, Err: err}
+			if err == nil && doc.Find(selector).Length() == 0 {
+				check.Err = fmt.Errorf("%v not found", selector)
+			}
+			checks = append(checks, check)
+		}
+	}
+	if cfg.UseGET {
+		checks = append(checks, checkGETFilter(client, cfg.Language, siteCfg, listDoc))
+	}
+	return checks, nil
+}
+
+// checkGETFilter searches the last expansion of the card list in listDoc both
+// with a POST like the site's form and with a GET, and fails if they don't
+// find as much.
+func checkGETFilter(client *http.Client, lang SiteLanguage, siteCfg siteConfig, listDoc *goquery.Document) SiteCheck {
+	link := fmt.Sprintf("%v?page=%d", siteCfg.cardSearchURL, 1)
+	check := SiteCheck{URL: link, Selector: getFilterCheck}
+	if listDoc == nil {
+		check.Err = fmt.Errorf("no card list to pick an expansion from")
+		return check
+	}
+	expansions, err := parseSelectOptions(listDoc.Selection, "expansion")
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	expansion := 0
+	for n := range expansions {
+		expansion = max(expansion, n)
+	}
+	values, err := searchValues(Config{Language: lang, ExpansionNumber: expansion}, siteCfg)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+
+	var sizes [2]int
+	for i, useGET := range []bool{false, true} {
+		doc, err := checkPage(client, link, values, useGET)
+		if err != nil {
+			check.Err = err
+			return check
+		}
+		sizes[i] = searchSize(siteCfg, doc)
+	}
+	if sizes[0] != sizes[1] {
+		check.Err = fmt.Errorf("expansion %d has a size of %d with GET but %d with POST, the site doesn't filter GET searches", expansion, sizes[1], sizes[0])
+	}
+	return check
+}
+
+// searchSize returns the number of results of the search page doc, or its
+// number of pages if the site doesn't show the results.
+func searchSize(c siteConfig, doc *goquery.Document) int {
+	if c.resultCountFunc != nil {
+		if n, err := c.resultCountFunc(doc); err == nil {
+			return n
+		}
+	}
+	return c.lastPageFunc(doc)
+}
+
+// checkPage sends values to pageURL like the scraper does and parses the
+// response.
+func checkPage(client *http.Client, pageURL string, values url.Values, useGET bool) (*goquery.Document, error) {
+	resp, err := search(client, pageURL, values, useGET)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read page: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
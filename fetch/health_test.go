@@ -0,0 +1,116 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCheckSite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cardlist/":
+			fmt.Fprint(w, `<select id="expansion"><option value="1">Set</option></select>`)
+		case "/cardlist/search":
+			// A changed layout without the results table.
+			fmt.Fprint(w, `<div class="results"></div>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := CheckSite(Config{
+		Language:   Japanese,
+		HTTPClient: &http.Client{Transport: rewriteTransport{target}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := map[string]bool{}
+	for _, c := range checks {
+		failed[c.Selector] = c.Err != nil
+	}
+	want := map[string]bool{"select#expansion": false, ".search-result-table": true}
+	if fmt.Sprint(failed) != fmt.Sprint(want) {
+		t.Errorf("got failed checks %v, want %v", failed, want)
+	}
+}
+
+func TestCheckSiteUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := CheckSite(Config{
+		Language:   English,
+		HTTPClient: &http.Client{Transport: rewriteTransport{target}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	for _, c := range checks {
+		if c.Err == nil {
+			t.Errorf("got no error for %v on %v, want the status code", c.Selector, c.URL)
+		}
+	}
+}
+
+func TestCheckSiteGET(t *testing.T) {
+	for _, filtersGET := range []bool{true, false} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/cardlist/" {
+				fmt.Fprint(w, `<select id="expansion"><option value="">All</option><option value="159">Set</option></select>`)
+				return
+			}
+			r.ParseForm()
+			filtered := r.PostForm.Get("expansion") == "159"
+			if filtersGET {
+				filtered = r.Form.Get("expansion") == "159"
+			}
+			count := 5000
+			if filtered {
+				count = 100
+			}
+			fmt.Fprintf(w, `<div class="c-search__results-item"><span>%d</span></div>`, count)
+		}))
+		target, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checks, err := CheckSite(Config{
+			Language:   English,
+			HTTPClient: &http.Client{Transport: rewriteTransport{target}},
+			UseGET:     true,
+		})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var getCheck *SiteCheck
+		for i := range checks {
+			if checks[i].Selector == getFilterCheck {
+				getCheck = &checks[i]
+			}
+		}
+		if getCheck == nil {
+			t.Fatalf("got checks %+v, want the GET check", checks)
+		}
+		if failed := getCheck.Err != nil; failed == filtersGET {
+			t.Errorf("site filtering GET=%v: got error %v", filtersGET, getCheck.Err)
+		}
+	}
+}
@@ -17,9 +17,13 @@ package fetch
 import (
 	"fmt"
 	"log/slog"
+	"net/http"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Akenaide/biri"
 	"github.com/PuerkitoBio/goquery"
@@ -33,8 +37,18 @@ var banProduct = []string{
 	"bp_renewal",
 }
 
+// productDetailWorkers is the number of product detail pages fetched at once.
+const productDetailWorkers = 4
+
+// maxProductPages caps how many products pages are read looking for a
+// release date.
+const maxProductPages = 10
+
 var titleAndWorkNumberRegexp = regexp.MustCompile(`.*/ .*：([\w,]+)`)
 
+// productSetCodeRegexp matches a set code like W109 or S108 in free text.
+var productSetCodeRegexp = regexp.MustCompile(`\b[WS]\d+\b`)
+
 // ProductInfo represents the extracted information from the HTML
 type ProductInfo struct {
 	ReleaseDate string
@@ -51,6 +65,13 @@ func getDocument(url string) *goquery.Document {
 		var err error
 		proxy := biri.GetClient()
 		resp, err := proxy.Client.Get(url)
+		if err == nil && resp.StatusCode == http.StatusNotFound {
+			// The page doesn't exist, another proxy won't change that.
+			resp.Body.Close()
+			proxy.Readd()
+			doc, _ = goquery.NewDocumentFromReader(strings.NewReader(""))
+			break
+		}
 		if err != nil || resp.StatusCode != 200 {
 			slog.Error(fmt.Sprintf("Error fetching page: %v", err))
 			proxy.Ban()
@@ -70,6 +91,29 @@ func getDocument(url string) *goquery.Document {
 	return doc
 }
 
+// clientDocument returns a getDocument that uses client instead of the
+// proxies. Pages that fail come back empty instead of being retried.
+func clientDocument(client *http.Client) func(string) *goquery.Document {
+	return func(url string) *goquery.Document {
+		resp, err := client.Get(url)
+		if err == nil {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("bad status code=%d", resp.StatusCode)
+			}
+		}
+		var doc *goquery.Document
+		if err == nil {
+			doc, err = goquery.NewDocumentFromReader(resp.Body)
+		}
+		if err != nil {
+			slog.With("url", url).Error(fmt.Sprintf("Error fetching page: %v", err))
+			doc, _ = goquery.NewDocumentFromReader(strings.NewReader(""))
+		}
+		return doc
+	}
+}
+
 func extractProductInfo(doc *goquery.Document) (ProductInfo, error) {
 	var setCode string
 	releaseDate := strings.Split(strings.TrimSpace(doc.Find(".release strong").Text()), "(")[0]
@@ -92,23 +136,155 @@ func extractProductInfo(doc *goquery.Document) (ProductInfo, error) {
 		}
 	})
 
+	title := doc.Find(".entry-content > h3").Text()
+	if setCode == "" {
+		setCode = fallbackSetCode(title, titleAndWorkNumber)
+	}
+
 	return ProductInfo{
 		ReleaseDate: releaseDate,
-		Title:       doc.Find(".entry-content > h3").Text(),
+		Title:       title,
 		LicenceCode: licenceCode,
 		SetCode:     setCode,
 		Image:       doc.Find(".product-detail .alignright img").AttrOr("src", "notfound"),
 	}, nil
 }
 
+// fallbackSetCode looks for a set code in the product title, then in the
+// release text, for when the banner images don't give one.
+func fallbackSetCode(title, titleAndWorkNumber string) string {
+	for _, source := range []struct{ name, text string }{
+		{"title", title},
+		{"release text", titleAndWorkNumber},
+	} {
+		if setCode := productSetCodeRegexp.FindString(source.text); setCode != "" {
+			slog.Debug(fmt.Sprintf("Set code %v taken from the product %v", setCode, source.name))
+			return setCode
+		}
+	}
+	return ""
+}
+
 func Products(page string) []ProductInfo {
 	biri.Config.PingServer = "https://ws-tcg.com/"
 	biri.Config.TickMinuteDuration = 1
 	biri.Config.Timeout = 25
 	biri.ProxyStart()
 
-	var productList []ProductInfo
-	doc := getDocument(ProductsUrl + page)
+	return fetchProducts(page, getDocument)
+}
+
+// isoReleaseDate turns a product release date like "2023/10/27" into
+// "2023-10-27".
+func isoReleaseDate(releaseDate string) (string, error) {
+	d, err := time.Parse("2006/1/2", strings.TrimSpace(releaseDate))
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse release date %q: %v", releaseDate, err)
+	}
+	return d.Format(time.DateOnly), nil
+}
+
+// releaseDates maps each product's set code (eg. W109) to its ISO release date.
+func releaseDates(products []ProductInfo) map[string]string {
+	dates := make(map[string]string)
+	for _, p := range products {
+		if p.SetCode == "" {
+			continue
+		}
+		date, err := isoReleaseDate(p.ReleaseDate)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Skipping release date of %v: %v", p.SetCode, err))
+			continue
+		}
+		dates[p.SetCode] = date
+	}
+	return dates
+}
+
+// releaseDateIndex looks up release dates, reading the products pages one
+// at a time until the release is found. Safe for concurrent use; a nil
+// index has no dates.
+type releaseDateIndex struct {
+	get func(string) *goquery.Document
+
+	// mu guards the fields below but isn't held while a page is read, so the
+	// dates already found don't wait for it.
+	mu       sync.Mutex
+	dates    map[string]string
+	nextPage int
+	done     bool
+	// reading is set while a page is read, and pageRead is broadcast when
+	// it's done.
+	reading  bool
+	pageRead *sync.Cond
+}
+
+func newReleaseDateIndex(get func(string) *goquery.Document) *releaseDateIndex {
+	r := &releaseDateIndex{get: get, dates: make(map[string]string), nextPage: 1}
+	r.pageRead = sync.NewCond(&r.mu)
+	return r
+}
+
+// date returns the ISO release date of release, or "" when it isn't found.
+// Only standard releases (eg. W109) are looked for past the pages already
+// read, the others never have a product page.
+func (r *releaseDateIndex) date(release string) string {
+	if r == nil || release == "" {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if date, ok := r.dates[release]; ok || r.done || releaseKind(release) != ReleaseStandard {
+			return date
+		}
+		if r.reading {
+			r.pageRead.Wait()
+			continue
+		}
+		if r.nextPage > maxProductPages {
+			r.done = true
+			continue
+		}
+		page := r.nextPage
+		r.nextPage++
+		r.reading = true
+		r.mu.Unlock()
+		dates, ok := r.readPage(page)
+		r.mu.Lock()
+		r.reading = false
+		r.pageRead.Broadcast()
+		if !ok {
+			r.done = true
+			continue
+		}
+		for code, date := range dates {
+			r.dates[code] = date
+		}
+		slog.Info(fmt.Sprintf("Found %d release dates", len(r.dates)))
+	}
+}
+
+// readPage gets the release dates of the products page page. ok is false
+// when the page lists no products, as past the last page.
+func (r *releaseDateIndex) readPage(page int) (dates map[string]string, ok bool) {
+	details := productLinks(strconv.Itoa(page), r.get)
+	if len(details) == 0 {
+		return nil, false
+	}
+	return releaseDates(fetchProductDetails(details, productDetailWorkers, minTimeBetweenRequests, r.get)), true
+}
+
+// fetchProducts gets a page of products with get, usually getDocument which
+// needs biri to be started already.
+func fetchProducts(page string, get func(string) *goquery.Document) []ProductInfo {
+	return fetchProductDetails(productLinks(page, get), productDetailWorkers, minTimeBetweenRequests, get)
+}
+
+// productLinks returns the product detail links listed on a products page.
+func productLinks(page string, get func(string) *goquery.Document) []string {
+	var details []string
+	doc := get(ProductsUrl + page)
 
 	doc.Find(".product-list .show-detail a").Each(func(i int, s *goquery.Selection) {
 		productDetail := s.AttrOr("href", "nope")
@@ -117,14 +293,50 @@ func Products(page string) []ProductInfo {
 				return
 			}
 		}
-		slog.Info(fmt.Sprintf("Extract: %v", productDetail))
-		doc := getDocument(productDetail)
-		if productInfo, err := extractProductInfo(doc); err != nil {
-			slog.Error(fmt.Sprintf("Error getting product info: %v", err))
-		} else {
-			productList = append(productList, productInfo)
-		}
+		details = append(details, productDetail)
 	})
 
+	return details
+}
+
+// fetchProductDetails extracts the product info of each detail page with a
+// bounded number of workers, starting at most one request per interval.
+// Products keep the order of details; pages that can't be parsed are skipped.
+func fetchProductDetails(details []string, workers int, interval time.Duration, get func(string) *goquery.Document) []ProductInfo {
+	results := make([]*ProductInfo, len(details))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				slog.Info(fmt.Sprintf("Extract: %v", details[i]))
+				productInfo, err := extractProductInfo(get(details[i]))
+				if err != nil {
+					slog.Error(fmt.Sprintf("Error getting product info: %v", err))
+					continue
+				}
+				results[i] = &productInfo
+			}
+		}()
+	}
+
+	for i := range details {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var productList []ProductInfo
+	for _, productInfo := range results {
+		if productInfo != nil {
+			productList = append(productList, *productInfo)
+		}
+	}
 	return productList
 }
@@ -2,7 +2,9 @@ package fetch
 
 import (
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -224,3 +226,197 @@ func TestExtractProductInfoUnexpectedTitle(t *testing.T) {
 		t.Error("Didn't get expected error")
 	}
 }
+
+func TestReleaseDates(t *testing.T) {
+	products := []ProductInfo{
+		{ReleaseDate: "2023/10/27", SetCode: "W109"},
+		{ReleaseDate: "2024/1/5", SetCode: "S108"},
+		{ReleaseDate: "2024/2/2", SetCode: ""},
+		{ReleaseDate: "soon", SetCode: "W110"},
+	}
+	dates := releaseDates(products)
+	want := map[string]string{
+		"W109": "2023-10-27",
+		"S108": "2024-01-05",
+	}
+	if len(dates) != len(want) {
+		t.Errorf("Got %d dates, want %d: %v", len(dates), len(want), dates)
+	}
+	for code, date := range want {
+		if dates[code] != date {
+			t.Errorf("Incorrect date for %v: got %q, want %q", code, dates[code], date)
+		}
+	}
+}
+
+func TestFetchProductDetails(t *testing.T) {
+	pages := map[string]string{
+		"a": productHTML,
+		"b": productHTMLUnexpectedTitle,
+		"c": productHTML,
+	}
+	get := func(url string) *goquery.Document {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(pages[url]))
+		if err != nil {
+			t.Error("Error parsing HTML:", err)
+		}
+		return doc
+	}
+
+	products := fetchProductDetails([]string{"a", "b", "c"}, 2, 0, get)
+	if len(products) != 2 {
+		t.Fatalf("got %d products: expected 2", len(products))
+	}
+	for _, p := range products {
+		if p.SetCode != "W109" {
+			t.Errorf("got set code %q: expected W109", p.SetCode)
+		}
+	}
+}
+
+func TestExtractProductInfoSetCodeFromTitle(t *testing.T) {
+	const html = `
+<div class="entry-content">
+<h3>ブースターパック 五等分の花嫁 W999</h3>
+<div class="product-detail">
+<div class="alignright"><img src="https://ws-tcg.com/box.png"></div>
+<p class="release"><strong>2024/10/25(Fri) 発売</strong><br>
+【
+タイトル区分：五等分の花嫁/ 作品番号：5HY】
+</p>
+</div>
+</div>
+`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal("Error parsing HTML:", err)
+	}
+	product, err := extractProductInfo(doc)
+	if err != nil {
+		t.Fatal("Got unexpected error: ", err)
+	}
+	if product.SetCode != "W999" {
+		t.Error("SetCode not good. Found: ", product.SetCode)
+	}
+}
+
+func TestFallbackSetCode(t *testing.T) {
+	tests := []struct {
+		title, release, want string
+	}{
+		{"ブースターパック S108", "タイトル区分：X/ 作品番号：W109", "S108"},
+		{"ブースターパック", "タイトル区分：X/ 作品番号：ABC W110", "W110"},
+		{"ブースターパック", "タイトル区分：X/ 作品番号：SWS", ""},
+	}
+	for _, tt := range tests {
+		if got := fallbackSetCode(tt.title, tt.release); got != tt.want {
+			t.Errorf("fallbackSetCode(%q, %q) = %q: expected %q", tt.title, tt.release, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseDateIndex(t *testing.T) {
+	listPage := func(href string) string {
+		return `<div class="product-list"><div class="show-detail"><a href="` + href + `">detail</a></div></div>`
+	}
+	pages := map[string]string{
+		ProductsUrl + "1": listPage("bad"),
+		ProductsUrl + "2": listPage("w109"),
+		"bad":             productHTMLUnexpectedTitle,
+		"w109":            productHTML,
+	}
+	var mu sync.Mutex
+	var requested []string
+	get := func(url string) *goquery.Document {
+		mu.Lock()
+		requested = append(requested, url)
+		mu.Unlock()
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(pages[url]))
+		if err != nil {
+			t.Error("Error parsing HTML:", err)
+		}
+		return doc
+	}
+	countListPages := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := 0
+		for _, url := range requested {
+			if strings.HasPrefix(url, ProductsUrl) {
+				n++
+			}
+		}
+		return n
+	}
+
+	index := newReleaseDateIndex(get)
+	if got := index.date("PR"); got != "" || countListPages() != 0 {
+		t.Errorf("Promo release: got date %q after %d products pages, expected none", got, countListPages())
+	}
+	if got := index.date("W109"); got != "2023-10-27" {
+		t.Errorf("Incorrect date for W109: got %q", got)
+	}
+	if n := countListPages(); n != 2 {
+		t.Errorf("Read %d products pages for W109: expected 2", n)
+	}
+	if got := index.date("W110"); got != "" {
+		t.Errorf("Incorrect date for W110: got %q, expected none", got)
+	}
+	if got := index.date("W111"); got != "" {
+		t.Errorf("Incorrect date for W111: got %q, expected none", got)
+	}
+	if n := countListPages(); n != 3 {
+		t.Errorf("Read %d products pages: expected 3, stopping at the first empty one", n)
+	}
+
+	var nilIndex *releaseDateIndex
+	if got := nilIndex.date("W109"); got != "" {
+		t.Errorf("nil index: got %q, expected none", got)
+	}
+}
+
+func TestReleaseDateIndexDoesntWaitForPages(t *testing.T) {
+	listPage := `<div class="product-list"><div class="show-detail"><a href="w109">detail</a></div></div>`
+	reading, release := make(chan struct{}), make(chan struct{})
+	get := func(url string) *goquery.Document {
+		page := ""
+		switch url {
+		case ProductsUrl + "1":
+			page = listPage
+		case ProductsUrl + "2":
+			// Hold the second page until the test is done with the cached date.
+			close(reading)
+			<-release
+		case "w109":
+			page = productHTML
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+		if err != nil {
+			t.Error("Error parsing HTML:", err)
+		}
+		return doc
+	}
+
+	index := newReleaseDateIndex(get)
+	if got := index.date("W109"); got != "2023-10-27" {
+		t.Fatalf("Incorrect date for W109: got %q", got)
+	}
+	missing := make(chan string)
+	go func() { missing <- index.date("W110") }()
+	<-reading
+
+	found := make(chan string)
+	go func() { found <- index.date("W109") }()
+	select {
+	case got := <-found:
+		if got != "2023-10-27" {
+			t.Errorf("Incorrect date for W109: got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("Looking up a known date waited for a products page")
+	}
+	close(release)
+	if got := <-missing; got != "" {
+		t.Errorf("Incorrect date for W110: got %q, expected none", got)
+	}
+}
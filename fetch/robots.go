@@ -0,0 +1,65 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/temoto/robotstxt"
+)
+
+// robotsAgent is the user agent looked up in robots.txt. The scraper uses Go's
+// default one.
+const robotsAgent = "Go-http-client"
+
+// robotsRules are the robots.txt rules that apply to the scraper. A nil
+// *robotsRules allows everything.
+type robotsRules struct {
+	group *robotstxt.Group
+}
+
+// fetchRobots gets and parses the robots.txt of the site at baseURL.
+func fetchRobots(client *http.Client, baseURL string) (*robotsRules, error) {
+	resp, err := client.Get(baseURL + "robots.txt")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get robots.txt: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse robots.txt: %v", err)
+	}
+	return &robotsRules{group: data.FindGroup(robotsAgent)}, nil
+}
+
+// allowed reports whether robots.txt allows fetching rawURL, logging when it
+// doesn't.
+func (r *robotsRules) allowed(rawURL string) bool {
+	if r == nil || r.group == nil {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	if r.group.Test(u.RequestURI()) {
+		return true
+	}
+	slog.With("url", rawURL).Warn("Skipping page disallowed by robots.txt")
+	return false
+}
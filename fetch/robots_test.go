@@ -0,0 +1,41 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRobotsRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "User-agent: *\nDisallow: /cardlist/searchresults/\n")
+	}))
+	defer srv.Close()
+
+	robots, err := fetchRobots(srv.Client(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if robots.allowed(srv.URL + "/cardlist/searchresults/?page=2") {
+		t.Error("expected the search results to be disallowed")
+	}
+	if !robots.allowed(srv.URL + "/cardlist/list/?cardno=BD/W63-036") {
+		t.Error("expected the detail page to be allowed")
+	}
+
+	task := scrapeTask{siteConfig: siteConfigs[English], robots: robots}
+	task.siteConfig.cardSearchURL = srv.URL + "/cardlist/searchresults/"
+	if _, err := task.getLastPage(); err == nil {
+		t.Error("expected an error getting the last page of a disallowed search")
+	}
+
+	var none *robotsRules
+	if !none.allowed(srv.URL + "/cardlist/searchresults/") {
+		t.Error("expected a nil robotsRules to allow everything")
+	}
+}
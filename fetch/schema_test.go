@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCardSchema(t *testing.T) {
+	schema := CardSchema()
+	properties := schema["properties"].(map[string]any)
+	required := schema["required"].([]string)
+
+	tests := map[string]string{
+		"cardNumber":  "string",
+		"hasCXCombo":  "boolean",
+		"traits":      "array",
+		"expansionId": "integer",
+		"extraFields": "object",
+	}
+	for name, want := range tests {
+		p, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Errorf("missing property %q", name)
+			continue
+		}
+		if p["type"] != want {
+			t.Errorf("got %v type %v, want %v", name, p["type"], want)
+		}
+	}
+	if _, ok := properties["Image"]; ok {
+		t.Error("Image isn't part of the JSON and shouldn't be in the schema")
+	}
+	if !slices.Contains(required, "cardNumber") || slices.Contains(required, "keywords") {
+		t.Errorf("got required %v: expected cardNumber but not the omitempty keywords", required)
+	}
+
+	costs := properties["abilityCosts"].(map[string]any)
+	items := costs["items"].(map[string]any)
+	itemProperties, _ := items["properties"].(map[string]any)
+	if items["type"] != "object" || len(itemProperties) == 0 {
+		t.Fatalf("got abilityCosts items %v: expected an object with properties", items)
+	}
+	if p, _ := itemProperties["stock"].(map[string]any); p["type"] != "integer" {
+		t.Errorf("got abilityCosts stock %v: expected an integer", itemProperties["stock"])
+	}
+	if itemRequired := items["required"].([]string); !slices.Contains(itemRequired, "raw") || slices.Contains(itemRequired, "stock") {
+		t.Errorf("got abilityCosts required %v: expected raw but not the omitempty stock", itemRequired)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxSitemapDepth is how many levels of sitemap indexes are followed.
+const maxSitemapDepth = 3
+
+// sitemap is either a sitemap index (Sitemaps) or a list of pages (URLs).
+type sitemap struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+// CardURLsFromSitemap returns the card detail page URLs listed in the site's
+// sitemap.xml, following sitemap indexes. It returns an error if the site has
+// no sitemap.
+func CardURLsFromSitemap(lang SiteLanguage) ([]string, error) {
+	siteCfg, ok := siteConfigs[lang]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %v", lang)
+	}
+	client := &http.Client{Timeout: lastPageTimeout}
+	return sitemapCardURLs(client, siteCfg.baseURL+"sitemap.xml", 0)
+}
+
+// sitemapCardURLs collects the URLs with a cardno parameter from the sitemap
+// at sitemapURL and the sitemaps it lists.
+func sitemapCardURLs(client *http.Client, sitemapURL string, depth int) ([]string, error) {
+	resp, err := client.Get(sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get sitemap %v: %v", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't get sitemap %v: bad status code=%d", sitemapURL, resp.StatusCode)
+	}
+	var sm sitemap
+	if err := xml.NewDecoder(resp.Body).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("couldn't parse sitemap %v: %v", sitemapURL, err)
+	}
+
+	var urls []string
+	for _, u := range sm.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if parsed, err := url.Parse(loc); err == nil && parsed.Query().Get("cardno") != "" {
+			urls = append(urls, loc)
+		}
+	}
+	if depth >= maxSitemapDepth {
+		return urls, nil
+	}
+	for _, s := range sm.Sitemaps {
+		sub, err := sitemapCardURLs(client, strings.TrimSpace(s.Loc), depth+1)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Skipping sitemap: %v", err))
+			continue
+		}
+		urls = append(urls, sub...)
+	}
+	return urls, nil
+}
@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSitemapCardURLs(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]v/cards.xml</loc></sitemap>
+<sitemap><loc>%[1]v/missing.xml</loc></sitemap>
+</sitemapindex>`, srv.URL)
+		case "/cards.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]v/cardlist/list/?cardno=BD/W63-036</loc></url>
+<url><loc>%[1]v/products/</loc></url>
+<url><loc> %[1]v/cardlist/list/?cardno=SS/WE41-E17 </loc></url>
+</urlset>`, srv.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	urls, err := sitemapCardURLs(srv.Client(), srv.URL+"/sitemap.xml", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		srv.URL + "/cardlist/list/?cardno=BD/W63-036",
+		srv.URL + "/cardlist/list/?cardno=SS/WE41-E17",
+	}
+	if !slices.Equal(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+
+	if _, err := sitemapCardURLs(srv.Client(), srv.URL+"/missing.xml", 0); err == nil {
+		t.Error("expected an error for a missing sitemap")
+	}
+}
+
+func TestCardsStreamSitemap_en(t *testing.T) {
+	var searches, details int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>https://en.ws-tcg.com/cardlist/list/?cardno=BD/EN-W03-004</loc></url>
+<url><loc>https://en.ws-tcg.com/cardlist/list/?cardno=BD/EN-W03-005</loc></url>
+</urlset>`)
+		case "/cardlist/list/":
+			atomic.AddInt64(&details, 1)
+			fmt.Fprintf(w, `<div class="p-cards__detail-wrapper"><div class="p-cards__detail-textarea"><p class="number">%v</p></div></div>`, r.URL.Query().Get("cardno"))
+		default:
+			atomic.AddInt64(&searches, 1)
+		}
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Language:          English,
+		HTTPClient:        &http.Client{Transport: rewriteTransport{target}},
+		WorkerStartJitter: -1,
+		UseSitemap:        true,
+	}
+	cardCh := make(chan Card, 10)
+	if err := CardsStream(cfg, cardCh); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for card := range cardCh {
+		got = append(got, card.CardNumber)
+	}
+	slices.Sort(got)
+	if want := []string{"BD/EN-W03-004", "BD/EN-W03-005"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if searches != 0 || details != 2 {
+		t.Errorf("got %d searches and %d detail pages, want only the 2 detail pages", searches, details)
+	}
+}
+
+func TestCardsStreamSitemapFilters(t *testing.T) {
+	cfg := Config{Language: English, UseSitemap: true, ExpansionNumber: 159}
+	if err := CardsStream(cfg, make(chan Card)); err == nil {
+		t.Error("expected an error for the sitemap with an expansion")
+	}
+}
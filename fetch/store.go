@@ -0,0 +1,120 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var cardsBucket = []byte("cards")
+
+// ErrCardNotFound is returned by Store.Get when the card isn't in the store.
+var ErrCardNotFound = errors.New("card not found")
+
+// Store is a BoltDB file of cards keyed by "lang/CardNumber", for looking cards
+// up without going through files.
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens the store at path, creating it if needed. Only one process
+// can have a store open at a time.
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open store %v: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(cardsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("couldn't prepare store %v: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func storeKey(lang, cardNumber string) []byte {
+	return []byte(lang + "/" + cardNumber)
+}
+
+// Put stores card under its Language and CardNumber. An existing card is only
+// replaced if overwrite is true. It returns whether the card was written.
+func (s *Store) Put(card Card, overwrite bool) (bool, error) {
+	data, err := json.Marshal(card)
+	if err != nil {
+		return false, err
+	}
+	written := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cardsBucket)
+		key := storeKey(card.Language, card.CardNumber)
+		if !overwrite && b.Get(key) != nil {
+			return nil
+		}
+		written = true
+		return b.Put(key, data)
+	})
+	return written, err
+}
+
+// Get returns the card with cardNumber in lang (e.g. "ja" or "en"), or
+// ErrCardNotFound.
+func (s *Store) Get(lang, cardNumber string) (Card, error) {
+	var card Card
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(cardsBucket).Get(storeKey(lang, cardNumber))
+		if data == nil {
+			return ErrCardNotFound
+		}
+		return json.Unmarshal(data, &card)
+	})
+	return card, err
+}
+
+// WriteCardStore puts every card from cardCh into s until it's closed. The
+// channel is always drained. A card that fails to be written is logged and the
+// first error is returned once the channel is closed.
+func WriteCardStore(s *Store, overwrite bool, cardCh <-chan Card) error {
+	var firstErr error
+	for card := range cardCh {
+		written, err := s.Put(card, overwrite)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error storing card %v: %v", card.CardNumber, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !written {
+			slog.Info(fmt.Sprintf("Skipping card %v (already stored)", card.CardNumber))
+			continue
+		}
+		slog.Debug(fmt.Sprintf("Finished card: %v", card.CardNumber))
+	}
+	return firstErr
+}
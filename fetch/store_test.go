@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s, err := OpenStore(filepath.Join(t.TempDir(), "cards.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	cardCh := make(chan Card, 3)
+	cardCh <- Card{CardNumber: "BD/W63-036", Language: "ja", Name: "上原ひまり"}
+	cardCh <- Card{CardNumber: "BD/W63-036", Language: "en", Name: "Himari Uehara"}
+	cardCh <- Card{CardNumber: "BD/W63-036", Language: "ja", Name: "renamed"}
+	close(cardCh)
+	if err := WriteCardStore(s, false, cardCh); err != nil {
+		t.Fatal(err)
+	}
+
+	card, err := s.Get("ja", "BD/W63-036")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Name != "上原ひまり" {
+		t.Errorf("got name %q: expected the first card to be kept", card.Name)
+	}
+	if card, err := s.Get("en", "BD/W63-036"); err != nil || card.Name != "Himari Uehara" {
+		t.Errorf("got %q, %v: expected the EN card", card.Name, err)
+	}
+
+	if _, err := s.Put(Card{CardNumber: "BD/W63-036", Language: "ja", Name: "renamed"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if card, _ := s.Get("ja", "BD/W63-036"); card.Name != "renamed" {
+		t.Errorf("got name %q: expected the card to be overwritten", card.Name)
+	}
+
+	if _, err := s.Get("ja", "BD/W63-999"); !errors.Is(err, ErrCardNotFound) {
+		t.Errorf("got %v: expected ErrCardNotFound", err)
+	}
+}
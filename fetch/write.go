@@ -0,0 +1,87 @@
+// Copyright © 2024
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// WriteCardJSON writes card to w as an indented JSON document, the format of
+// the CLI's card files.
+func WriteCardJSON(w io.Writer, card Card) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(card)
+}
+
+// WriteCardsJSON writes cards to w as an indented JSON array.
+func WriteCardsJSON(w io.Writer, cards []Card) error {
+	if cards == nil {
+		cards = []Card{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(cards)
+}
+
+// WriteCardJSONL writes one JSON document per line as cards arrive on cardCh,
+// until it's closed. If w has a Flush method (e.g. gzip.Writer or
+// bufio.Writer) it's flushed after every card so readers see cards as soon as
+// they're fetched.
+//
+// The channel is always drained. A card that fails to be written is logged and
+// the first error is returned once the channel is closed.
+func WriteCardJSONL(w io.Writer, cardCh <-chan Card) error {
+	flusher, _ := w.(interface{ Flush() error })
+	enc := json.NewEncoder(w)
+	var firstErr error
+	for card := range cardCh {
+		err := enc.Encode(card)
+		if err == nil && flusher != nil {
+			err = flusher.Flush()
+		}
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error writing card %v: %v", card.CardNumber, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		slog.Debug(fmt.Sprintf("Finished card: %v", card.CardNumber))
+	}
+	return firstErr
+}
+
+// WriteChecklistCSV writes a collector's checklist of cards to w as CSV, sorted
+// by card number, with an empty "owned" column to fill in.
+func WriteChecklistCSV(w io.Writer, cards []Card) error {
+	sorted := slices.Clone(cards)
+	slices.SortFunc(sorted, func(a, b Card) int {
+		return strings.Compare(a.CardNumber, b.CardNumber)
+	})
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"cardNumber", "name", "rarity", "owned"})
+	for _, card := range sorted {
+		cw.Write([]string{card.CardNumber, card.Name, card.Rarity, ""})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteCardJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCardJSON(&buf, Card{CardNumber: "BD/W63-036", Name: "A & B"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "{\n\t\"cardNumber\": \"BD/W63-036\"") {
+		t.Errorf("got %q: expected an indented card", buf.String())
+	}
+	var got Card
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "A & B" {
+		t.Errorf("got name %q: expected A & B", got.Name)
+	}
+}
+
+func TestWriteCardsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	cards := []Card{{CardNumber: "BD/W63-036"}, {CardNumber: "BD/W63-037"}}
+	if err := WriteCardsJSON(&buf, cards); err != nil {
+		t.Fatal(err)
+	}
+	var got []Card
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].CardNumber != "BD/W63-037" {
+		t.Errorf("got %+v: expected both cards back", got)
+	}
+
+	buf.Reset()
+	if err := WriteCardsJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("got %q: expected []", got)
+	}
+}
+
+func TestWriteCardJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriterSize(&buf, 1<<16)
+	cardCh := make(chan Card, 2)
+	cardCh <- Card{CardNumber: "BD/W63-036"}
+	cardCh <- Card{CardNumber: "BD/W63-037"}
+	close(cardCh)
+
+	if err := WriteCardJSONL(w, cardCh); err != nil {
+		t.Fatal(err)
+	}
+	// Flushed after every card, nothing left buffered.
+	if w.Buffered() != 0 {
+		t.Errorf("got %d buffered bytes: expected 0", w.Buffered())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: expected 2", len(lines))
+	}
+	var card Card
+	if err := json.Unmarshal([]byte(lines[0]), &card); err != nil {
+		t.Fatal(err)
+	}
+	if card.CardNumber != "BD/W63-036" {
+		t.Errorf("got %v: expected BD/W63-036", card.CardNumber)
+	}
+}
+
+func TestWriteChecklistCSV(t *testing.T) {
+	var buf bytes.Buffer
+	cards := []Card{
+		{CardNumber: "BD/W63-037", Name: "Moca, Aoba", Rarity: "R"},
+		{CardNumber: "BD/W63-036", Name: "上原ひまり", Rarity: "RR"},
+	}
+	if err := WriteChecklistCSV(&buf, cards); err != nil {
+		t.Fatal(err)
+	}
+	want := "cardNumber,name,rarity,owned\n" +
+		"BD/W63-036,上原ひまり,RR,\n" +
+		"BD/W63-037,\"Moca, Aoba\",R,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if cards[0].CardNumber != "BD/W63-037" {
+		t.Error("the cards given shouldn't be reordered")
+	}
+}
@@ -8,6 +8,8 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/viper v1.15.0
+	github.com/temoto/robotstxt v1.1.2
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/net v0.23.0
 	golang.org/x/text v0.14.0
 )